@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+// dockerHubServer is the key the docker CLI stores Docker Hub credentials under
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// Credentials for a registry, all empty for anonymous access.
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+type dockerConfig struct {
+	Auths       map[string]authEntry `json:"auths"`
+	CredHelpers map[string]string    `json:"credHelpers"`
+	CredsStore  string               `json:"credsStore"`
+}
+
+type authEntry struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// Lookup finds the credentials for registry the way the docker CLI does: a
+// credHelpers entry for the registry first, then the credsStore, then the
+// auths in the docker config file itself.
+func Lookup(registry string) (Credentials, error) {
+	cfg, err := readDockerConfig()
+	if err != nil {
+		return Credentials{}, err
+	}
+	server := serverAddress(registry)
+	for key, helper := range cfg.CredHelpers {
+		if serverAddress(key) == server {
+			return fromHelper(helper, server)
+		}
+	}
+	if cfg.CredsStore != "" {
+		return fromHelper(cfg.CredsStore, server)
+	}
+	for key, entry := range cfg.Auths {
+		if serverAddress(key) == server {
+			return entry.credentials(key)
+		}
+	}
+	return Credentials{}, nil
+}
+
+// RegistryAuth returns the credentials for the registry of ref encoded for the
+// docker daemon API, or "" for anonymous access.
+func RegistryAuth(ref string) (string, error) {
+	r, err := name.ParseReference(ref, name.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+	creds, err := Lookup(r.Context().RegistryStr())
+	if err != nil || creds == (Credentials{}) {
+		return "", err
+	}
+	buf, err := json.Marshal(dockertypes.AuthConfig{
+		Username:      creds.Username,
+		Password:      creds.Password,
+		IdentityToken: creds.IdentityToken,
+		ServerAddress: serverAddress(r.Context().RegistryStr()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(buf), nil
+}
+
+// Keychain resolves registry credentials from the docker config. Identity
+// tokens are refresh tokens for an OAuth2 exchange the registry client does not
+// do, so credentials with only an identity token are an error rather than
+// silently anonymous.
+type Keychain struct{}
+
+func (Keychain) Resolve(registry name.Registry) (authn.Authenticator, error) {
+	creds, err := Lookup(registry.RegistryStr())
+	if err != nil {
+		return nil, err
+	}
+	if creds.Username == "" && creds.IdentityToken != "" {
+		return nil, fmt.Errorf("credentials for '%s' only have an identity token, which is not supported for registry access: log in with a username and password", registry.RegistryStr())
+	}
+	if creds.Username == "" {
+		return authn.Anonymous, nil
+	}
+	return &authn.Basic{Username: creds.Username, Password: creds.Password}, nil
+}
+
+func readDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".docker")
+	}
+	path := filepath.Join(dir, "config.json")
+	cfg := &dockerConfig{}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, cfg); err != nil {
+		return nil, errors.Wrapf(err, "parse docker config '%s'", path)
+	}
+	return cfg, nil
+}
+
+func (e authEntry) credentials(server string) (Credentials, error) {
+	creds := Credentials{Username: e.Username, Password: e.Password, IdentityToken: e.IdentityToken}
+	if e.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return Credentials{}, errors.Wrapf(err, "decode auth for '%s' in docker config", server)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return Credentials{}, fmt.Errorf("invalid auth for '%s' in docker config: must be username:password", server)
+		}
+		creds.Username, creds.Password = parts[0], parts[1]
+	}
+	return creds, nil
+}
+
+// fromHelper asks docker-credential-<helper> for the credentials of server.
+// Having none stored is not an error, access is then anonymous.
+func fromHelper(helper, server string) (Credentials, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(output, "credentials not found") {
+			return Credentials{}, nil
+		}
+		return Credentials{}, errors.Wrapf(err, "credential helper '%s' for '%s': %s", helper, server, output)
+	}
+	var resp struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return Credentials{}, errors.Wrapf(err, "parse output of credential helper '%s'", helper)
+	}
+	if resp.Username == "<token>" {
+		return Credentials{IdentityToken: resp.Secret}, nil
+	}
+	return Credentials{Username: resp.Username, Password: resp.Secret}, nil
+}
+
+// serverAddress turns a registry host or docker config key into the key the
+// docker CLI uses, so "docker.io", "index.docker.io" and
+// "https://index.docker.io/v1/" are all the same server.
+func serverAddress(registry string) string {
+	host := registry
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host = strings.SplitN(host, "/", 2)[0]
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return dockerHubServer
+	}
+	return host
+}
@@ -0,0 +1,203 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpack/pack/auth"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestAuth(t *testing.T) {
+	spec.Run(t, "auth", testAuth, spec.Report(report.Terminal{}))
+}
+
+func testAuth(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir, oldDockerConfig, oldPath string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.auth.test.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldDockerConfig, oldPath = os.Getenv("DOCKER_CONFIG"), os.Getenv("PATH")
+		os.Setenv("DOCKER_CONFIG", tmpDir)
+		os.Setenv("PATH", tmpDir+string(os.PathListSeparator)+oldPath)
+	})
+
+	it.After(func() {
+		os.Setenv("DOCKER_CONFIG", oldDockerConfig)
+		os.Setenv("PATH", oldPath)
+		os.RemoveAll(tmpDir)
+	})
+
+	writeConfig := func(contents string) {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeHelper := func(name, script string) {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, "docker-credential-"+name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	when("#Lookup", func() {
+		it("is anonymous without a docker config", func() {
+			creds, err := auth.Lookup("registry.example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if creds != (auth.Credentials{}) {
+				t.Fatalf("expected no credentials, got %+v", creds)
+			}
+		})
+
+		it("decodes auths entries", func() {
+			writeConfig(`{"auths": {"https://registry.example.com": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("some-user:some:password")) + `"}}}`)
+
+			creds, err := auth.Lookup("registry.example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if creds != (auth.Credentials{Username: "some-user", Password: "some:password"}) {
+				t.Fatalf("unexpected credentials %+v", creds)
+			}
+		})
+
+		it("treats the Docker Hub names as one registry", func() {
+			writeConfig(`{"auths": {"https://index.docker.io/v1/": {"username": "hub-user", "password": "hub-password"}}}`)
+
+			creds, err := auth.Lookup("index.docker.io")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if creds.Username != "hub-user" {
+				t.Fatalf("expected hub credentials, got %+v", creds)
+			}
+		})
+
+		it("prefers a credential helper for the registry over the credential store", func() {
+			writeConfig(`{"credsStore": "store", "credHelpers": {"registry.example.com": "helper"}}`)
+			writeHelper("helper", `read server; echo "{\"ServerURL\":\"$server\",\"Username\":\"helper-user\",\"Secret\":\"helper-secret\"}"`)
+			writeHelper("store", `echo "credentials not found in native keychain"; exit 1`)
+
+			creds, err := auth.Lookup("registry.example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if creds != (auth.Credentials{Username: "helper-user", Password: "helper-secret"}) {
+				t.Fatalf("unexpected credentials %+v", creds)
+			}
+
+			creds, err = auth.Lookup("other.example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if creds != (auth.Credentials{}) {
+				t.Fatalf("expected no credentials from the store, got %+v", creds)
+			}
+		})
+
+		it("returns an error when a credential helper fails", func() {
+			writeConfig(`{"credsStore": "broken"}`)
+			writeHelper("broken", `echo "something went wrong" >&2; exit 1`)
+
+			if _, err := auth.Lookup("registry.example.com"); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	})
+
+	when("#RegistryAuth", func() {
+		it("encodes the credentials for the registry of the image", func() {
+			writeConfig(`{"auths": {"registry.example.com": {"username": "some-user", "password": "some-password"}}}`)
+
+			encoded, err := auth.RegistryAuth("registry.example.com/some/image:tag")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			buf, err := base64.URLEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var authConfig map[string]string
+			if err := json.Unmarshal(buf, &authConfig); err != nil {
+				t.Fatal(err)
+			}
+			if authConfig["username"] != "some-user" || authConfig["password"] != "some-password" || authConfig["serveraddress"] != "registry.example.com" {
+				t.Fatalf("unexpected auth config %v", authConfig)
+			}
+		})
+
+		it("is empty for anonymous access", func() {
+			encoded, err := auth.RegistryAuth("some/image")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if encoded != "" {
+				t.Fatalf("expected no auth, got %s", encoded)
+			}
+		})
+	})
+	when("#Keychain", func() {
+		it("resolves username and password credentials to basic auth", func() {
+			writeConfig(`{"auths": {"registry.example.com": {"username": "some-user", "password": "some-password"}}}`)
+
+			authenticator, err := auth.Keychain{}.Resolve(registry(t, "registry.example.com"))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if _, ok := authenticator.(*authn.Basic); !ok {
+				t.Fatalf("expected basic auth, got %T", authenticator)
+			}
+		})
+
+		it("returns an error for an identity token from a credential helper", func() {
+			writeConfig(`{"credHelpers": {"registry.example.com": "helper"}}`)
+			writeHelper("helper", `echo '{"Username":"<token>","Secret":"some-token"}'`)
+
+			_, err := auth.Keychain{}.Resolve(registry(t, "registry.example.com"))
+			if err == nil || !strings.Contains(err.Error(), "credentials for 'registry.example.com' only have an identity token") {
+				t.Fatalf("expected identity token error, got %v", err)
+			}
+		})
+
+		it("returns an error for an identity token in the docker config", func() {
+			writeConfig(`{"auths": {"registry.example.com": {"identitytoken": "some-token"}}}`)
+
+			_, err := auth.Keychain{}.Resolve(registry(t, "registry.example.com"))
+			if err == nil || !strings.Contains(err.Error(), "credentials for 'registry.example.com' only have an identity token") {
+				t.Fatalf("expected identity token error, got %v", err)
+			}
+		})
+
+		it("is anonymous without credentials", func() {
+			authenticator, err := auth.Keychain{}.Resolve(registry(t, "registry.example.com"))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if authenticator != authn.Anonymous {
+				t.Fatalf("expected anonymous access, got %T", authenticator)
+			}
+		})
+	})
+}
+
+func registry(t *testing.T, host string) name.Registry {
+	r, err := name.NewRegistry(host, name.WeakValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
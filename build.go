@@ -3,87 +3,136 @@ package pack
 import (
 	"archive/tar"
 	"context"
-	"crypto/md5"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/buildpack/pack/image"
 
 	"github.com/buildpack/pack/config"
 	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
 
 	"github.com/BurntSushi/toml"
 	"github.com/buildpack/lifecycle"
 	"github.com/buildpack/pack/docker"
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
 	dockercli "github.com/docker/docker/client"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
+// DefaultBuilder is used when neither a flag nor project.toml names a builder.
+const DefaultBuilder = "packs/samples"
+
 type BuildFactory struct {
 	Cli    Docker
 	Stdout io.Writer
 	Stderr io.Writer
-	Log    *log.Logger
+	Log    logging.Logger
 	FS     FS
 	Config *config.Config
 	Images Images
+	Events Events
 }
 
 type BuildFlags struct {
-	AppDir     string
-	Builder    string
-	RunImage   string
-	RepoName   string
-	Publish    bool
-	NoPull     bool
-	Buildpacks []string
+	AppDir       string
+	Builder      string
+	RunImage     string
+	RepoName     string
+	Tags         []string
+	Publish      bool
+	PullPolicy   PullPolicy
+	Buildpacks   []string
+	Env          []string
+	EnvFile      string
+	Exclude      []string
+	ClearCache   bool
+	Report       string
+	Reproducible bool
+	Network      string
+	Volumes      []string
+	// Export is an image file to write instead of the daemon or registry,
+	// "oci:<dir>" or "docker-archive:<file>"
+	Export string
+	// CacheImage is a registry image the cache is restored from and saved to,
+	// for runners that do not keep the cache volume between builds
+	CacheImage string
+	// DefaultProcess is the process type from launch.toml the image starts
+	DefaultProcess string
+	// PersistentWorkspace keeps the app in a volume between builds and only
+	// copies what changed, for the quick rebuilds of pack run
+	PersistentWorkspace bool
+	// detectOnly skips the checks and pulls only needed after detection
+	detectOnly bool
 }
 
 type BuildConfig struct {
-	AppDir     string
-	Builder    string
-	RunImage   string
-	RepoName   string
-	Publish    bool
-	Buildpacks []string
+	AppDir         string
+	Builder        string
+	RunImage       string
+	RepoName       string
+	Tags           []string
+	Publish        bool
+	Buildpacks     []string
+	Env            map[string]string
+	Exclude        []string
+	Include        []string
+	ClearCache     bool
+	ReportPath     string
+	Reproducible   bool
+	Network        string
+	Volumes        []string
+	ExportFile     string
+	CacheImage     string
+	DefaultProcess string
 	// Above are copied from BuildFlags are set by init
 	Cli    Docker
 	Stdout io.Writer
 	Stderr io.Writer
-	Log    *log.Logger
+	Log    logging.Logger
 	FS     FS
 	Config *config.Config
 	Images Images
+	Events Events
 	// Above are copied from BuildFactory
 	WorkspaceVolume string
 	CacheVolume     string
+	AppSource       string // the app as given, AppDir may be a temporary fetch of it
+	// AppVolume keeps the app between builds when set, see PersistentWorkspace
+	AppVolume       string
+	LocalBuildpacks []LocalBuildpack
+	cleanup         func()
+	report          *BuildReport
 }
 
-func DefaultBuildFactory() (*BuildFactory, error) {
+func DefaultBuildFactory(log logging.Logger) (*BuildFactory, error) {
 	f := &BuildFactory{
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-		Log:    log.New(os.Stdout, "", log.LstdFlags),
+		Stdout: log.Writer(),
+		Stderr: log.ErrorWriter(),
+		Log:    log,
 		FS:     &fs.FS{},
 		Images: &image.Client{},
+		Events: &TextEvents{Log: log},
 	}
 
-	var err error
-	f.Cli, err = docker.New()
+	cli, err := docker.New()
 	if err != nil {
 		return nil, err
 	}
+	cli.PullOut = log.Writer()
+	f.Cli = cli
 
 	f.Config, err = config.New(filepath.Join(os.Getenv("HOME"), ".pack"))
 	if err != nil {
@@ -93,21 +142,118 @@ func DefaultBuildFactory() (*BuildFactory, error) {
 	return f, nil
 }
 
-func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error) {
+// UseJSONOutput makes builds emit one JSON event per line to out. Log lines, pull
+// progress and container output are wrapped as log events.
+func (bf *BuildFactory) UseJSONOutput(out io.Writer) {
+	events := NewJSONEvents(out)
+	bf.Events = events
+	bf.Stdout = events.Writer("stdout")
+	bf.Stderr = events.Writer("stderr")
+	bf.Log = logging.New(events.Writer("log"), events.Writer("log"), logging.Options{})
+	if cli, ok := bf.Cli.(*docker.Client); ok {
+		cli.PullOut = events.Writer("log")
+	}
+}
+
+func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (_ *BuildConfig, err error) {
 	if f.AppDir == "current working directory" { // default placeholder
 		var err error
 		f.AppDir, err = os.Getwd()
 		if err != nil {
 			return nil, err
 		}
-		bf.Log.Printf("Defaulting app directory to current working directory '%s' (use --path to override)", f.AppDir)
+		bf.Log.Info("Defaulting app directory to current working directory '%s' (use --path to override)", f.AppDir)
+	}
+	source, err := parseSource(f.AppDir)
+	if err != nil {
+		return nil, err
+	}
+	if source.kind != sourceDir {
+		bf.Log.Info("Fetching app from '%s'", source.Identity())
+	}
+	appDir, cleanupApp, err := source.Fetch(bf.FS)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch app")
+	}
+	cleanup := cleanupApp
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+	project, err := ReadProject(appDir)
+	if err != nil {
+		return nil, err
+	}
+	f.mergeProject(project, appDir)
+	if !f.detectOnly {
+		if f.RepoName == "" {
+			return nil, fmt.Errorf(`an image name is required, as an argument or as "image" in %s`, ProjectFileName)
+		}
+		for _, tag := range append([]string{f.RepoName}, f.Tags...) {
+			if _, err := name.NewTag(tag, name.WeakValidation); err != nil {
+				return nil, fmt.Errorf(`invalid image name "%s": %s`, tag, err)
+			}
+		}
+		if f.Export != "" {
+			if f.Publish || len(f.Tags) > 0 {
+				return nil, fmt.Errorf("--export cannot be combined with --publish or --tag")
+			}
+			store, err := image.NewFileStore(f.Export)
+			if err != nil {
+				return nil, err
+			}
+			if store.Name == "" {
+				store.Name = f.RepoName
+			}
+			f.Export = store.String()
+		}
+		if f.CacheImage != "" {
+			if _, err := name.ParseReference(f.CacheImage, name.WeakValidation); err != nil {
+				return nil, fmt.Errorf(`invalid cache image name "%s": %s`, f.CacheImage, err)
+			}
+		}
+	}
+	env, err := parseEnv(f.EnvFile, f.Env)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range project.Build.Env {
+		if _, ok := env[k]; !ok {
+			env[k] = v
+		}
+	}
+	if f.Reproducible {
+		if _, err := sourceDate(env); err != nil {
+			return nil, err
+		}
+	}
+	exclude, err := fs.ReadIgnoreFile(appDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", fs.IgnoreFileName)
 	}
-	appDir, err := filepath.Abs(f.AppDir)
+	exclude = append(append(project.Build.Exclude, exclude...), f.Exclude...)
+	buildpacks, localBuildpacks, cleanupBuildpacks, err := resolveBuildpacks(bf.FS, f.Buildpacks)
 	if err != nil {
 		return nil, err
 	}
-	if !f.NoPull {
-		bf.Log.Printf("Pulling builder image '%s' (use --no-pull flag to skip this step)", f.Builder)
+	cleanup = func() {
+		cleanupApp()
+		cleanupBuildpacks()
+	}
+	var volumes []string
+	for _, v := range f.Volumes {
+		bind, err := parseVolume(v)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, bind)
+	}
+	if pull, err := shouldPull(bf.Cli, f.PullPolicy, f.Builder); err != nil {
+		return nil, err
+	} else if pull {
+		bf.Log.Info("Pulling builder image '%s' (use --pull-policy to change when images are pulled)", f.Builder)
+		bf.emit(Event{Type: EventPull, Image: f.Builder})
 		if err := bf.Cli.PullImage(f.Builder); err != nil {
 			return nil, err
 		}
@@ -117,8 +263,20 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		AppDir:          appDir,
 		Builder:         f.Builder,
 		RepoName:        f.RepoName,
+		Tags:            f.Tags,
 		Publish:         f.Publish,
-		Buildpacks:      f.Buildpacks,
+		Buildpacks:      buildpacks,
+		Env:             env,
+		Exclude:         exclude,
+		Include:         project.Build.Include,
+		ClearCache:      f.ClearCache,
+		ReportPath:      f.Report,
+		Reproducible:    f.Reproducible,
+		Network:         f.Network,
+		Volumes:         volumes,
+		ExportFile:      f.Export,
+		CacheImage:      f.CacheImage,
+		DefaultProcess:  f.DefaultProcess,
 		Cli:             bf.Cli,
 		Stdout:          bf.Stdout,
 		Stderr:          bf.Stderr,
@@ -126,8 +284,15 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		FS:              bf.FS,
 		Config:          bf.Config,
 		Images:          bf.Images,
+		Events:          bf.Events,
 		WorkspaceVolume: fmt.Sprintf("pack-workspace-%x", uuid.New().String()),
-		CacheVolume:     fmt.Sprintf("pack-cache-%x", md5.Sum([]byte(appDir))),
+		CacheVolume:     CacheVolumeName(source.Identity()),
+		AppSource:       source.Identity(),
+		LocalBuildpacks: localBuildpacks,
+		cleanup:         cleanup,
+	}
+	if f.PersistentWorkspace {
+		b.AppVolume = AppVolumeName(source.Identity())
 	}
 
 	builderStackID, err := b.imageLabel(f.Builder, "io.buildpacks.stack.id", true)
@@ -137,13 +302,16 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 	if builderStackID == "" {
 		return nil, fmt.Errorf(`invalid builder image "%s": missing required label "io.buildpacks.stack.id"`, b.Builder)
 	}
+	if f.detectOnly {
+		return b, nil
+	}
 	stack, err := bf.Config.Get(builderStackID)
 	if err != nil {
 		return nil, err
 	}
 
 	if f.RunImage != "" {
-		bf.Log.Printf("Using user provided run image '%s'", f.RunImage)
+		bf.Log.Info("Using user provided run image '%s'", f.RunImage)
 		b.RunImage = f.RunImage
 	} else {
 		reg, err := config.Registry(f.RepoName)
@@ -154,13 +322,18 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 		if err != nil {
 			return nil, err
 		}
-		b.Log.Printf("Selected run image '%s' from stack '%s'\n", b.RunImage, builderStackID)
+		b.Log.Info("Selected run image '%s' from stack '%s'", b.RunImage, builderStackID)
 	}
 
-	if !f.NoPull && !f.Publish {
-		bf.Log.Printf("Pulling run image '%s' (use --no-pull flag to skip this step)", b.RunImage)
-		if err := bf.Cli.PullImage(b.RunImage); err != nil {
+	if !f.Publish {
+		if pull, err := shouldPull(bf.Cli, f.PullPolicy, b.RunImage); err != nil {
 			return nil, err
+		} else if pull {
+			bf.Log.Info("Pulling run image '%s' (use --pull-policy to change when images are pulled)", b.RunImage)
+			bf.emit(Event{Type: EventPull, Image: b.RunImage})
+			if err := bf.Cli.PullImage(b.RunImage); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -175,8 +348,120 @@ func (bf *BuildFactory) BuildConfigFromFlags(f *BuildFlags) (*BuildConfig, error
 	return b, nil
 }
 
+// mergeProject fills in what was not given as a flag from project.toml. Local
+// buildpacks in it are relative to the app dir.
+func (f *BuildFlags) mergeProject(project *Project, appDir string) {
+	if f.RepoName == "" {
+		f.RepoName = project.Image
+	}
+	if f.Builder == "" {
+		f.Builder = project.Build.Builder
+	}
+	if f.Builder == "" {
+		f.Builder = DefaultBuilder
+	}
+	if f.RunImage == "" {
+		f.RunImage = project.Build.RunImage
+	}
+	if len(f.Buildpacks) == 0 {
+		for _, bp := range project.Build.Buildpacks {
+			if isLocalBuildpack(bp) && !filepath.IsAbs(bp) {
+				bp = filepath.Join(appDir, bp)
+			}
+			f.Buildpacks = append(f.Buildpacks, bp)
+		}
+	}
+}
+
+// parseVolume turns a host:container[:ro|rw] flag into a bind for the detect
+// and build containers. Nothing may be mounted into the workspace, which is
+// what gets exported, and the cache and lifecycle may only be mounted over
+// read-only.
+func parseVolume(volume string) (string, error) {
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid volume '%s': must be host:container[:ro]", volume)
+	}
+	mode := "rw"
+	if len(parts) == 3 {
+		mode = parts[2]
+		if mode != "ro" && mode != "rw" {
+			return "", fmt.Errorf("invalid volume '%s': mode must be 'ro' or 'rw'", volume)
+		}
+	}
+
+	hostPath, err := filepath.Abs(parts[0])
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(hostPath); err != nil {
+		return "", fmt.Errorf("invalid volume '%s': %s", volume, err)
+	}
+	ctrPath := path.Clean(parts[1])
+	if !path.IsAbs(ctrPath) {
+		return "", fmt.Errorf("invalid volume '%s': container path must be absolute", volume)
+	}
+	if isUnder(ctrPath, "/workspace") {
+		return "", fmt.Errorf("invalid volume '%s': cannot mount into /workspace", volume)
+	}
+	if mode == "rw" && (ctrPath == "/" || isUnder(ctrPath, "/cache") || isUnder(ctrPath, "/lifecycle")) {
+		return "", fmt.Errorf("invalid volume '%s': %s can only be mounted read-only", volume, ctrPath)
+	}
+	return hostPath + ":" + ctrPath + ":" + mode, nil
+}
+
+func isUnder(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+func parseEnv(envFile string, envs []string) (map[string]string, error) {
+	env := map[string]string{}
+	if envFile != "" {
+		txt, err := ioutil.ReadFile(envFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read env file '%s'", envFile)
+		}
+		for _, line := range strings.Split(string(txt), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := addEnvVar(env, line); err != nil {
+				return nil, errors.Wrapf(err, "parse env file '%s'", envFile)
+			}
+		}
+	}
+	for _, kv := range envs {
+		if err := addEnvVar(env, kv); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+func addEnvVar(env map[string]string, kv string) error {
+	parts := strings.SplitN(kv, "=", 2)
+	key := strings.TrimSpace(parts[0])
+	if !validEnvKey(key) {
+		return fmt.Errorf("invalid env var '%s'", kv)
+	}
+	if len(parts) == 1 {
+		// no value given, take it from the current environment like `docker run -e KEY`
+		env[key] = os.Getenv(key)
+	} else {
+		env[key] = parts[1]
+	}
+	return nil
+}
+
+// validEnvKey reports whether key can be used as a file name in the platform
+// env dir without leaving it.
+func validEnvKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, "/\\=")
+}
+
 func Build(appDir, buildImage, runImage, repoName string, publish bool) error {
-	bf, err := DefaultBuildFactory()
+	bf, err := DefaultBuildFactory(logging.New(os.Stdout, os.Stderr, logging.Options{}))
 	if err != nil {
 		return err
 	}
@@ -194,47 +479,154 @@ func Build(appDir, buildImage, runImage, repoName string, publish bool) error {
 }
 
 func (b *BuildConfig) Run() error {
+	return b.RunContext(context.Background())
+}
+
+// RunContext runs all build phases. When ctx is cancelled, the phase in
+// progress stops, its container is removed and the workspace volume deleted.
+func (b *BuildConfig) RunContext(ctx context.Context) error {
+	if b.cleanup != nil {
+		defer b.cleanup()
+	}
+	// cleanup uses its own context, ctx may already be cancelled by then
 	defer b.Cli.VolumeRemove(context.Background(), b.WorkspaceVolume, true)
 
-	group, err := b.Detect()
-	if err != nil {
+	err := b.run(ctx)
+	if err != nil && ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "build interrupted")
+	}
+	return err
+}
+
+func (b *BuildConfig) run(ctx context.Context) error {
+	if b.ClearCache {
+		b.Log.Info("Clearing cache volume '%s'", b.CacheVolume)
+		if err := b.Cli.VolumeRemove(ctx, b.CacheVolume, true); err != nil && !dockercli.IsErrNotFound(err) {
+			return errors.Wrap(err, "clear cache volume")
+		}
+	}
+	if b.ReportPath != "" {
+		b.report = &BuildReport{}
+		if err := b.reportImages(ctx); err != nil {
+			return errors.Wrap(err, "build report")
+		}
+	}
+
+	detectBanner := "DETECTING:"
+	if len(b.Buildpacks) > 0 {
+		detectBanner = "DETECTING WITH MANUALLY-PROVIDED GROUP:"
+	}
+	var group *lifecycle.BuildpackGroup
+	if err := b.runPhase("detect", detectBanner, func() error {
+		var err error
+		group, err = b.Detect(ctx)
+		return err
+	}); err != nil {
 		return err
 	}
+	groupEvent := Event{Type: EventGroup}
+	for _, bp := range group.Buildpacks {
+		groupEvent.Buildpacks = append(groupEvent.Buildpacks, EventBuildpack{ID: bp.ID, Version: bp.Version})
+	}
+	b.emit(groupEvent)
+	b.report.setGroup(group)
 
-	fmt.Println("*** ANALYZING: Reading information from previous image for possible re-use")
-	if err := b.Analyze(); err != nil {
+	if err := b.runPhase("analyze", "ANALYZING: Reading information from previous image for possible re-use", func() error {
+		return b.Analyze(ctx)
+	}); err != nil {
 		return err
 	}
 
-	fmt.Println("*** BUILDING:")
-	if err := b.Build(); err != nil {
+	// a cleared cache starts empty, it is still exported afterwards
+	if b.CacheImage != "" && !b.ClearCache {
+		if err := b.runPhase("restore-cache", "RESTORING CACHE: "+b.CacheImage, func() error {
+			return b.RestoreCache(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := b.runPhase("build", "BUILDING:", func() error {
+		return b.Build(ctx)
+	}); err != nil {
 		return err
 	}
+	if err := b.Config.TouchCache(b.CacheVolume, b.AppSource, time.Now()); err != nil {
+		b.Log.Warn("failed to record cache usage: %s", err)
+	}
+
+	if b.CacheImage != "" {
+		if err := b.runPhase("export-cache", "EXPORTING CACHE: "+b.CacheImage, func() error {
+			return b.ExportCache(ctx)
+		}); err != nil {
+			return err
+		}
+	}
 
-	fmt.Println("*** EXPORTING:")
-	if err := b.Export(group); err != nil {
+	if err := b.runPhase("export", "EXPORTING:", func() error {
+		return b.Export(ctx, group)
+	}); err != nil {
 		return err
 	}
 
+	if b.report != nil {
+		if err := b.report.Write(b.ReportPath); err != nil {
+			return err
+		}
+		b.Log.Info("Wrote build report to '%s'", b.ReportPath)
+	}
 	return nil
 }
 
-func parseBuildpack(ref string) (string, string) {
+func (b *BuildConfig) runPhase(phase, banner string, fn func() error) error {
+	b.emit(Event{Type: EventPhaseStart, Phase: phase, Message: banner})
+	start := time.Now()
+	err := fn()
+	b.Log.Verbose("%s phase took %s", phase, time.Since(start).Round(time.Millisecond))
+	end := Event{Type: EventPhaseEnd, Phase: phase, Duration: time.Since(start).Seconds()}
+	if err != nil {
+		end.Error = err.Error()
+	}
+	b.emit(end)
+	if err != nil {
+		b.emit(Event{Type: EventError, Phase: phase, Error: err.Error()})
+	}
+	return err
+}
+
+// removeContainer force removes a lifecycle container, stopping it if it is
+// still running. It ignores the build context so it also works after an interrupt.
+func (b *BuildConfig) removeContainer(id string) {
+	b.Cli.ContainerRemove(context.Background(), id, dockertypes.ContainerRemoveOptions{Force: true})
+}
+
+func (b *BuildConfig) emit(e Event) {
+	if b.Events == nil {
+		b.Events = &TextEvents{Log: b.Log}
+	}
+	b.Events.Emit(e)
+}
+
+func (bf *BuildFactory) emit(e Event) {
+	if bf.Events != nil {
+		bf.Events.Emit(e)
+	}
+}
+
+func (b *BuildConfig) parseBuildpack(ref string) (string, string) {
 	parts := strings.Split(ref, "@")
 	if len(parts) == 2 {
 		return parts[0], parts[1]
 	}
-	fmt.Printf("No version for '%s' buildpack provided, will use '%s@latest'\n", parts[0], parts[0])
+	b.Log.Info("No version for '%s' buildpack provided, will use '%s@latest'", parts[0], parts[0])
 	return parts[0], "latest"
 }
 
-func (b *BuildConfig) Detect() (*lifecycle.BuildpackGroup, error) {
+func (b *BuildConfig) Detect(ctx context.Context) (*lifecycle.BuildpackGroup, error) {
 	var orderToml string
 	if len(b.Buildpacks) == 0 {
-		fmt.Println("*** DETECTING:")
 		orderToml = "" // use order toml already in image
 	} else {
-		fmt.Println("*** DETECTING WITH MANUALLY-PROVIDED GROUP:")
 		var order struct {
 			Groups lifecycle.BuildpackOrder `toml:"groups"`
 		}
@@ -245,7 +637,7 @@ func (b *BuildConfig) Detect() (*lifecycle.BuildpackGroup, error) {
 		}
 
 		for _, bp := range b.Buildpacks {
-			id, version := parseBuildpack(bp)
+			id, version := b.parseBuildpack(bp)
 			order.Groups[0].Buildpacks = append(
 				order.Groups[0].Buildpacks,
 				&lifecycle.Buildpack{ID: id, Version: version, Optional: false},
@@ -261,42 +653,46 @@ func (b *BuildConfig) Detect() (*lifecycle.BuildpackGroup, error) {
 		orderToml = tomlBuilder.String()
 	}
 
-	var cmd []string
+	cmd := []string{"/lifecycle/detector"}
 	if orderToml != "" {
-		cmd = []string{"/lifecycle/detector", "-order", "/workspace/app/pack-order.toml"}
-	} else {
-		cmd = []string{"/lifecycle/detector"}
+		cmd = append(cmd, "-order", "/workspace/app/pack-order.toml")
+	}
+	if len(b.Env) > 0 {
+		cmd = append(cmd, "-platform", "/workspace/platform")
 	}
 
-	ctx := context.Background()
+	// created up front rather than on first use, so it carries the label
+	if _, err := b.Cli.VolumeCreate(ctx, volume.VolumeCreateBody{Name: b.WorkspaceVolume, Labels: managedLabels()}); err != nil {
+		return nil, errors.Wrap(err, "create workspace volume")
+	}
+	b.Log.Verbose("Created workspace volume '%s'", b.WorkspaceVolume)
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
-		Cmd:   cmd,
+		Image:  b.Builder,
+		Cmd:    cmd,
+		Env:    proxyEnv(),
+		Labels: managedLabels(),
 	}, &container.HostConfig{
-		Binds: []string{
+		Binds: append([]string{
 			b.WorkspaceVolume + ":/workspace",
-		},
+		}, b.Volumes...),
+		NetworkMode: container.NetworkMode(b.Network),
 	}, nil, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 
-	uid, gid, err := b.packUidGid(b.Builder)
+	uid, gid, err := b.packUidGid(ctx, b.Builder)
 	if err != nil {
 		return nil, errors.Wrap(err, "detect")
 	}
 
-	tr, errChan := b.FS.CreateTarReader(b.AppDir, "/workspace/app", uid, gid)
-	if err := b.Cli.CopyToContainer(ctx, ctr.ID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
-		return nil, errors.Wrap(err, "copy app to workspace volume")
-	}
-	if err := <-errChan; err != nil {
-		return nil, errors.Wrap(err, "copy app to workspace volume")
-	}
-
-	if err := b.chownDir("/workspace/app", uid, gid); err != nil {
-		return nil, errors.Wrap(err, "chown app to workspace volume")
+	if b.AppVolume != "" {
+		if err := b.syncAppVolume(ctx, uid, gid); err != nil {
+			return nil, err
+		}
+	} else if err := b.copyApp(ctx, ctr.ID, uid, gid); err != nil {
+		return nil, err
 	}
 
 	if orderToml != "" {
@@ -309,14 +705,39 @@ func (b *BuildConfig) Detect() (*lifecycle.BuildpackGroup, error) {
 		}
 	}
 
+	if err := b.copyEnvToContainer(ctx, ctr.ID); err != nil {
+		return nil, err
+	}
+
+	if err := b.copyLocalBuildpacks(ctx, ctr.ID); err != nil {
+		return nil, err
+	}
+
 	if err := b.Cli.RunContainer(ctx, ctr.ID, b.Stdout, b.Stderr); err != nil {
 		return nil, errors.Wrap(err, "run detect container")
 	}
-	return b.groupToml(ctr.ID)
+	return b.groupToml(ctx, ctr.ID)
+}
+
+// copyEnvToContainer writes each build env var as a file in the platform env
+// directory of the workspace volume, where both the detector and builder read
+// them. Nothing outside app, config and the buildpack layers is exported, so
+// these never reach the final image.
+func (b *BuildConfig) copyEnvToContainer(ctx context.Context, ctrID string) error {
+	for k, v := range b.Env {
+		tr, err := b.FS.CreateSingleFileTar(filepath.Join("/workspace/platform/env", k), v)
+		if err != nil {
+			return errors.Wrap(err, "converting env var to tar reader")
+		}
+		if err := b.Cli.CopyToContainer(ctx, ctrID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
+			return errors.Wrapf(err, "creating env var %s in platform dir", k)
+		}
+	}
+	return nil
 }
 
-func (b *BuildConfig) groupToml(ctrID string) (*lifecycle.BuildpackGroup, error) {
-	trc, _, err := b.Cli.CopyFromContainer(context.Background(), ctrID, "/workspace/group.toml")
+func (b *BuildConfig) groupToml(ctx context.Context, ctrID string) (*lifecycle.BuildpackGroup, error) {
+	trc, _, err := b.Cli.CopyFromContainer(ctx, ctrID, "/workspace/group.toml")
 	if err != nil {
 		return nil, errors.Wrap(err, "reading group.toml from container")
 	}
@@ -333,33 +754,34 @@ func (b *BuildConfig) groupToml(ctrID string) (*lifecycle.BuildpackGroup, error)
 	return &group, nil
 }
 
-func (b *BuildConfig) Analyze() error {
-	metadata, err := b.imageLabel(b.RepoName, lifecycle.MetadataLabel, !b.Publish)
+func (b *BuildConfig) Analyze(ctx context.Context) error {
+	previous, useDaemon := b.RepoName, !b.Publish
+	if b.ExportFile != "" {
+		previous, useDaemon = b.ExportFile, false
+	}
+	metadata, err := b.imageLabel(previous, lifecycle.MetadataLabel, useDaemon)
 	if err != nil {
 		return errors.Wrap(err, "analyze image label")
 	}
 	if metadata == "" {
-		if b.Publish {
-			b.Log.Printf("WARNING: skipping analyze, image not found or requires authentication to access")
-		} else {
-			b.Log.Printf("WARNING: skipping analyze, image not found")
-		}
+		b.Log.Warn("skipping analyze, image not found")
 		return nil
 	}
 
-	ctx := context.Background()
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
-		Cmd:   []string{"/lifecycle/analyzer", "-metadata", "/workspace/imagemetadata.json", "-launch", "/workspace", b.RepoName},
+		Image:  b.Builder,
+		Cmd:    []string{"/lifecycle/analyzer", "-metadata", "/workspace/imagemetadata.json", "-launch", "/workspace", b.RepoName},
+		Labels: managedLabels(),
 	}, &container.HostConfig{
 		Binds: []string{
 			b.WorkspaceVolume + ":/workspace",
 		},
+		NetworkMode: container.NetworkMode(b.Network),
 	}, nil, "")
 	if err != nil {
 		return errors.Wrap(err, "analyze container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 
 	tr, err := b.FS.CreateSingleFileTar("/workspace/imagemetadata.json", metadata)
 	if err != nil {
@@ -375,52 +797,167 @@ func (b *BuildConfig) Analyze() error {
 	return nil
 }
 
-func (b *BuildConfig) Build() error {
-	ctx := context.Background()
+// proxyEnv returns the proxy settings of pack's own environment, to be set on
+// the detect and build containers. Only the workspace is exported, so they
+// never end up in the app image.
+func proxyEnv() []string {
+	var env []string
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+func (b *BuildConfig) Build(ctx context.Context) error {
+	b.Log.Verbose("Using cache volume '%s'", b.CacheVolume)
+	cmd := []string{"/lifecycle/builder"}
+	if len(b.Env) > 0 {
+		cmd = append(cmd, "-platform", "/workspace/platform")
+	}
+
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
-		Cmd:   []string{"/lifecycle/builder"},
+		Image:  b.Builder,
+		Cmd:    cmd,
+		Env:    proxyEnv(),
+		Labels: managedLabels(),
 	}, &container.HostConfig{
-		Binds: []string{
+		Binds: append([]string{
 			b.WorkspaceVolume + ":/workspace",
 			b.CacheVolume + ":/cache",
-		},
+		}, b.Volumes...),
+		NetworkMode: container.NetworkMode(b.Network),
 	}, nil, "")
 	if err != nil {
 		return errors.Wrap(err, "build container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
+
+	if err := b.copyLocalBuildpacks(ctx, ctr.ID); err != nil {
+		return err
+	}
 
 	return b.Cli.RunContainer(ctx, ctr.ID, b.Stdout, b.Stderr)
 }
 
-func (b *BuildConfig) Export(group *lifecycle.BuildpackGroup) error {
-	if b.Publish {
-		localWorkspaceDir, cleanup, err := b.exportVolume(b.Builder, b.WorkspaceVolume)
+// Export assembles the app image from the workspace with the lifecycle
+// exporter, the same way for the registry and the daemon, so both end up with
+// the same layers and metadata.
+func (b *BuildConfig) Export(ctx context.Context, group *lifecycle.BuildpackGroup) error {
+	localWorkspaceDir, cleanup, err := b.exportVolume(ctx, b.Builder, b.WorkspaceVolume)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var created time.Time
+	if b.Reproducible {
+		if created, err = sourceDate(b.Env); err != nil {
+			return err
+		}
+		if err := normalizeModTimes(localWorkspaceDir, created); err != nil {
+			return errors.Wrap(err, "normalize file dates")
+		}
+	}
+
+	for _, bp := range group.Buildpacks {
+		layers, err := workspaceLayers(filepath.Join(localWorkspaceDir, bp.ID))
 		if err != nil {
 			return err
 		}
-		defer cleanup()
+		for _, layer := range layers {
+			b.emit(layerEvent(bp.ID, layer.name, layer.reused))
+			b.report.addLayer(bp.ID, layer.name, layer.reused)
+		}
+	}
+	if b.DefaultProcess != "" {
+		if err := checkProcessType(localWorkspaceDir, group, b.DefaultProcess); err != nil {
+			return err
+		}
+	}
 
-		imgSHA, err := exportRegistry(group, localWorkspaceDir, b.RepoName, b.RunImage, b.Stdout, b.Stderr)
+	if b.Publish {
+		imgSHA, err := exportImage(group, localWorkspaceDir, b.RepoName, b.Tags, b.RunImage, false, created, nil, b.DefaultProcess, b.Stdout, b.Stderr)
 		if err != nil {
 			return err
 		}
-		b.Log.Printf("\n*** Image: %s@%s\n", b.RepoName, imgSHA)
-	} else {
-		var buildpacks []string
-		for _, b := range group.Buildpacks {
-			buildpacks = append(buildpacks, b.ID)
+		b.Log.Result("\n*** Image: %s@%s", b.RepoName, imgSHA)
+		for _, tag := range b.Tags {
+			b.Log.Result("*** Image: %s@%s", tag, imgSHA)
 		}
+		b.emit(Event{Type: EventImage, Image: b.RepoName, Digest: imgSHA})
+		if b.report != nil {
+			b.report.Image = ReportImage{Name: b.RepoName, Digest: imgSHA, Tags: b.Tags}
+		}
+		return nil
+	}
 
-		if err := exportDaemon(b.Cli, buildpacks, b.WorkspaceVolume, b.RepoName, b.RunImage, b.Stdout); err != nil {
+	if b.ExportFile != "" {
+		// the run image is still read from the daemon, it was pulled there
+		imgSHA, err := exportImage(group, localWorkspaceDir, b.ExportFile, nil, b.RunImage, true, created, nil, b.DefaultProcess, b.Stdout, b.Stderr)
+		if err != nil {
 			return err
 		}
+		b.Log.Result("\n*** Image: %s@%s written to %s", b.RepoName, imgSHA, b.ExportFile)
+		b.emit(Event{Type: EventImage, Image: b.RepoName, Digest: imgSHA})
+		if b.report != nil {
+			b.report.Image = ReportImage{Name: b.RepoName, Digest: imgSHA}
+		}
+		return nil
+	}
+
+	// pack run images are replaced on every run, the label lets pack prune
+	// find the old ones once they are dangling
+	var labels map[string]string
+	if strings.HasPrefix(b.RepoName, runRepoPrefix) {
+		labels = managedLabels()
+	}
+	// the image is loaded once, other tags are added to it in the daemon
+	if _, err := exportImage(group, localWorkspaceDir, b.RepoName, nil, b.RunImage, true, created, labels, b.DefaultProcess, b.Stdout, b.Stderr); err != nil {
+		return err
+	}
+	for _, tag := range b.Tags {
+		if err := b.Cli.ImageTag(ctx, b.RepoName, tag); err != nil {
+			return errors.Wrapf(err, "tag image '%s'", tag)
+		}
 	}
 
+	i, _, err := b.Cli.ImageInspectWithRaw(ctx, b.RepoName)
+	if err != nil {
+		return errors.Wrap(err, "inspect exported image")
+	}
+	b.emit(Event{Type: EventImage, Image: b.RepoName, Digest: i.ID})
+	if b.report != nil {
+		b.report.Image = ReportImage{Name: b.RepoName, ID: i.ID, Tags: b.Tags}
+	}
 	return nil
 }
 
+type workspaceLayer struct {
+	name   string
+	reused bool
+}
+
+// workspaceLayers lists the layers a buildpack left in its workspace dir. A
+// layer with metadata but no contents will be reused from the previous image.
+func workspaceLayers(bpDir string) ([]workspaceLayer, error) {
+	tomls, err := filepath.Glob(filepath.Join(bpDir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	var layers []workspaceLayer
+	for _, tomlPath := range tomls {
+		name := strings.TrimSuffix(filepath.Base(tomlPath), ".toml")
+		if name == "launch" {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(bpDir, name))
+		layers = append(layers, workspaceLayer{name: name, reused: os.IsNotExist(err)})
+	}
+	return layers, nil
+}
+
 func (b *BuildConfig) imageLabel(repoName, key string, useDaemon bool) (string, error) {
 	var labels map[string]string
 	if useDaemon {
@@ -438,11 +975,10 @@ func (b *BuildConfig) imageLabel(repoName, key string, useDaemon bool) (string,
 		}
 		config, err := origImage.ConfigFile()
 		if err != nil {
-			if remoteErr, ok := err.(*remote.Error); ok && len(remoteErr.Errors) > 0 {
-				switch remoteErr.Errors[0].Code {
-				case remote.UnauthorizedErrorCode, remote.ManifestUnknownErrorCode:
-					return "", nil
-				}
+			if err := image.RegistryError(repoName, err); err == nil {
+				return "", nil
+			} else if _, ok := err.(*image.AuthError); ok {
+				return "", err
 			}
 			return "", errors.Wrapf(err, "access manifest: %s", repoName)
 		}
@@ -452,8 +988,8 @@ func (b *BuildConfig) imageLabel(repoName, key string, useDaemon bool) (string,
 	return labels[key], nil
 }
 
-func (b *BuildConfig) packUidGid(builder string) (int, int, error) {
-	i, _, err := b.Cli.ImageInspectWithRaw(context.Background(), builder)
+func (b *BuildConfig) packUidGid(ctx context.Context, builder string) (int, int, error) {
+	i, _, err := b.Cli.ImageInspectWithRaw(ctx, builder)
 	if err != nil {
 		return 0, 0, errors.Wrap(err, "reading builder env variables")
 	}
@@ -480,32 +1016,11 @@ func (b *BuildConfig) packUidGid(builder string) (int, int, error) {
 	return uid, gid, nil
 }
 
-func (b *BuildConfig) chownDir(path string, uid, gid int) error {
-	ctx := context.Background()
-	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
-		Cmd:   []string{"chown", "-R", fmt.Sprintf("%d:%d", uid, gid), path},
-		User:  "root",
-	}, &container.HostConfig{
-		Binds: []string{
-			b.WorkspaceVolume + ":/workspace",
-		},
-	}, nil, "")
-	if err != nil {
-		return err
-	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
-	if err := b.Cli.RunContainer(ctx, ctr.ID, b.Stdout, b.Stderr); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (b *BuildConfig) exportVolume(image, volName string) (string, func(), error) {
-	ctx := context.Background()
+func (b *BuildConfig) exportVolume(ctx context.Context, image, volName string) (string, func(), error) {
 	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
-		Image: b.Builder,
-		Cmd:   []string{"true"},
+		Image:  b.Builder,
+		Cmd:    []string{"true"},
+		Labels: managedLabels(),
 	}, &container.HostConfig{
 		Binds: []string{
 			b.WorkspaceVolume + ":/workspace:ro",
@@ -514,7 +1029,7 @@ func (b *BuildConfig) exportVolume(image, volName string) (string, func(), error
 	if err != nil {
 		return "", func() {}, errors.Wrap(err, "export container create")
 	}
-	defer b.Cli.ContainerRemove(ctx, ctr.ID, dockertypes.ContainerRemoveOptions{})
+	defer b.removeContainer(ctr.ID)
 
 	r, _, err := b.Cli.CopyFromContainer(ctx, ctr.ID, "/workspace")
 	if err != nil {
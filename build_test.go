@@ -2,7 +2,9 @@ package pack_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -21,12 +23,15 @@ import (
 	"github.com/buildpack/pack/docker"
 	"github.com/buildpack/pack/fs"
 	"github.com/buildpack/pack/image"
+	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/mocks"
 	dockertypes "github.com/docker/docker/api/types"
 	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/uuid"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -56,7 +61,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			CacheVolume:     fmt.Sprintf("pack-cache-%x", uuid.New().String()),
 			Stdout:          &buf,
 			Stderr:          &buf,
-			Log:             log.New(&buf, "", log.LstdFlags|log.Lshortfile),
+			Log:             logging.New(&buf, &buf, logging.Options{}),
 			FS:              &fs.FS{},
 			Images:          &image.Client{},
 		}
@@ -89,7 +94,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					},
 				},
 				Cli: mockDocker,
-				Log: log.New(&buf, "", log.LstdFlags|log.Lshortfile),
+				Log: logging.New(&buf, &buf, logging.Options{}),
 			}
 		})
 
@@ -141,6 +146,52 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			assertEq(t, config.RunImage, "registry.com/some/run")
 		})
 
+		it("only pulls images missing from the daemon when pull policy is if-not-present", func() {
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil).Times(2)
+			gomock.InOrder(
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{}, nil, notFoundError{}),
+				mockDocker.EXPECT().PullImage("some/run"),
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil),
+			)
+
+			config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName:   "some/app",
+				Builder:    "some/builder",
+				PullPolicy: pack.PullIfNotPresent,
+			})
+			assertNil(t, err)
+			assertEq(t, config.RunImage, "some/run")
+		})
+
+		it("doesn't pull images when pull policy is never", func() {
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+
+			config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName:   "some/app",
+				Builder:    "some/builder",
+				PullPolicy: pack.PullNever,
+			})
+			assertNil(t, err)
+			assertEq(t, config.RunImage, "some/run")
+		})
+
 		it("doesn't pull run images when --publish is passed", func() {
 			mockDocker.EXPECT().PullImage("some/builder")
 			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
@@ -167,6 +218,28 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			assertEq(t, config.RunImage, "some/run")
 		})
 
+		it("reports a registry refusing access to the run image as an auth failure", func() {
+			mockDocker.EXPECT().PullImage("some/builder")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+			mockRunImage := mocks.NewMockImage(mockController)
+			mockImages.EXPECT().ReadImage("some/run", false).Return(mockRunImage, nil)
+			mockRunImage.EXPECT().ConfigFile().Return(nil, &remote.Error{
+				Errors: []remote.Diagnostic{{Code: remote.UnauthorizedErrorCode, Message: "authentication required"}},
+			})
+
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+				Publish:  true,
+			})
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), `invalid run image "some/run": not authorized to access image 'some/run'`)
+		})
+
 		it("allows run-image from flags if the stacks match", func() {
 			mockDocker.EXPECT().PullImage("some/builder")
 			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
@@ -249,6 +322,515 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			assertEq(t, config.AppDir, os.Getenv("PWD"))
 		})
 
+		it("sets build env from --env-file and --env, with --env taking precedence", func() {
+			mockDocker.EXPECT().PullImage("some/builder")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+			mockDocker.EXPECT().PullImage("some/run")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+
+			envFile, err := ioutil.TempFile("", "pack.build.envfile.")
+			assertNil(t, err)
+			defer os.Remove(envFile.Name())
+			_, err = envFile.WriteString("# comment\nBP_JAVA_VERSION=8\n\nHTTP_PROXY=http://file-proxy\n")
+			assertNil(t, err)
+			assertNil(t, envFile.Close())
+
+			config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+				EnvFile:  envFile.Name(),
+				Env:      []string{"HTTP_PROXY=http://flag-proxy", "EMPTY="},
+			})
+			assertNil(t, err)
+			assertEq(t, config.Env, map[string]string{
+				"BP_JAVA_VERSION": "8",
+				"HTTP_PROXY":      "http://flag-proxy",
+				"EMPTY":           "",
+			})
+		})
+
+		it("returns an error when an env var is invalid", func() {
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+				Env:      []string{"=value"},
+			})
+			assertError(t, err, "invalid env var '=value'")
+		})
+
+		when("the app has a project.toml", func() {
+			var appDir string
+
+			it.Before(func() {
+				var err error
+				appDir, err = ioutil.TempDir("", "pack.build.project.")
+				assertNil(t, err)
+				assertNil(t, ioutil.WriteFile(filepath.Join(appDir, "project.toml"), []byte(`
+image = "project/app"
+
+[build]
+builder = "project/builder"
+run-image = "project/run"
+buildpacks = ["project.bp@1.0"]
+include = ["src/"]
+exclude = ["*.log"]
+
+[build.env]
+FROM_PROJECT = "project"
+OVERRIDDEN = "project"
+`), 0644))
+			})
+
+			it.After(func() {
+				os.RemoveAll(appDir)
+			})
+
+			it("uses its settings for everything not given as a flag", func() {
+				mockDocker.EXPECT().PullImage("project/builder")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "project/builder").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+				mockDocker.EXPECT().PullImage("override/run")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "override/run").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   appDir,
+					RunImage: "override/run",
+					Env:      []string{"OVERRIDDEN=flag"},
+					Exclude:  []string{"tmp/"},
+				})
+				assertNil(t, err)
+				assertEq(t, config.RepoName, "project/app")
+				assertEq(t, config.Builder, "project/builder")
+				assertEq(t, config.RunImage, "override/run")
+				assertEq(t, config.Buildpacks, []string{"project.bp@1.0"})
+				assertEq(t, config.Env, map[string]string{
+					"FROM_PROJECT": "project",
+					"OVERRIDDEN":   "flag",
+				})
+				assertEq(t, config.Include, []string{"src/"})
+				assertEq(t, config.Exclude, []string{"*.log", "tmp/"})
+			})
+
+			it("returns an error pointing at an invalid key", func() {
+				assertNil(t, ioutil.WriteFile(filepath.Join(appDir, "project.toml"), []byte(`
+[build]
+builder = "Project/Builder"
+`), 0644))
+
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					AppDir:   appDir,
+				})
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), `invalid "build.builder" in project.toml`)
+			})
+		})
+
+		when("a buildpack is a local path", func() {
+			var bpDir string
+
+			it.Before(func() {
+				var err error
+				bpDir, err = ioutil.TempDir("", "pack.build.buildpack.")
+				assertNil(t, err)
+				assertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(`
+[buildpack]
+id = "some.local.bp"
+version = "1.2.3"
+name = "Some Local Buildpack"
+`), 0644))
+
+				mockDocker.EXPECT().PullImage("some/builder")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+				mockDocker.EXPECT().PullImage("some/run")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+			})
+
+			it.After(func() {
+				os.RemoveAll(bpDir)
+			})
+
+			it("adds a directory to the order by its id and version", func() {
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{"some.builder.bp@1.0", bpDir},
+				})
+				assertNil(t, err)
+				assertEq(t, config.Buildpacks, []string{"some.builder.bp@1.0", "some.local.bp@1.2.3"})
+				assertEq(t, config.LocalBuildpacks, []pack.LocalBuildpack{
+					{ID: "some.local.bp", Version: "1.2.3", Dir: bpDir},
+				})
+			})
+
+			it("extracts a .tgz", func() {
+				tgz := filepath.Join(bpDir, "..", filepath.Base(bpDir)+".tgz")
+				assertNil(t, (&fs.FS{}).CreateTGZFile(tgz, bpDir, "/", 0, 0))
+				defer os.Remove(tgz)
+
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName:   "some/app",
+					Builder:    "some/builder",
+					Buildpacks: []string{tgz},
+				})
+				assertNil(t, err)
+				assertEq(t, config.Buildpacks, []string{"some.local.bp@1.2.3"})
+				assertEq(t, len(config.LocalBuildpacks), 1)
+				contents, err := ioutil.ReadFile(filepath.Join(config.LocalBuildpacks[0].Dir, "buildpack.toml"))
+				assertNil(t, err)
+				assertContains(t, string(contents), `id = "some.local.bp"`)
+			})
+		})
+
+		it("returns an error for a local buildpack without a buildpack.toml", func() {
+			bpDir, err := ioutil.TempDir("", "pack.build.buildpack.")
+			assertNil(t, err)
+			defer os.RemoveAll(bpDir)
+
+			_, err = factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName:   "some/app",
+				Builder:    "some/builder",
+				Buildpacks: []string{bpDir},
+			})
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), "read buildpack.toml")
+		})
+
+		it("returns an error when no image name is given", func() {
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				Builder: "some/builder",
+			})
+			assertError(t, err, `an image name is required, as an argument or as "image" in project.toml`)
+		})
+
+		it("keeps additional tags", func() {
+			mockDocker.EXPECT().PullImage("some/builder")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+			mockDocker.EXPECT().PullImage("some/run")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+
+			config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app:sha",
+				Tags:     []string{"some/app:master", "some/app:latest"},
+				Builder:  "some/builder",
+			})
+			assertNil(t, err)
+			assertEq(t, config.RepoName, "some/app:sha")
+			assertEq(t, config.Tags, []string{"some/app:master", "some/app:latest"})
+		})
+
+		it("returns an error for an invalid tag before pulling anything", func() {
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Tags:     []string{"some/app:latest", "Some/App:Bad Tag"},
+				Builder:  "some/builder",
+			})
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), `invalid image name "Some/App:Bad Tag"`)
+		})
+
+		it("names the image in the export file after the app image", func() {
+			mockDocker.EXPECT().PullImage("some/builder")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+			mockDocker.EXPECT().PullImage("some/run")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+
+			config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+				Export:   "docker-archive:/tmp/app.tar",
+			})
+			assertNil(t, err)
+			assertEq(t, config.ExportFile, "docker-archive:/tmp/app.tar:some/app")
+		})
+
+		it("returns an error when exporting to a file is combined with publishing", func() {
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+				Publish:  true,
+				Export:   "oci:/tmp/app",
+			})
+			assertError(t, err, "--export cannot be combined with --publish or --tag")
+		})
+
+		it("returns an error for an unknown export format", func() {
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName: "some/app",
+				Builder:  "some/builder",
+				Export:   "tar:/tmp/app.tar",
+			})
+			assertError(t, err, "invalid image file 'tar:/tmp/app.tar': must be oci:<dir> or docker-archive:<file>")
+		})
+
+		it("returns an error for an invalid SOURCE_DATE_EPOCH in reproducible mode", func() {
+			_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+				RepoName:     "some/app",
+				Builder:      "some/builder",
+				Env:          []string{"SOURCE_DATE_EPOCH=yesterday"},
+				Reproducible: true,
+			})
+			assertError(t, err, "invalid SOURCE_DATE_EPOCH 'yesterday': must be a number of seconds since the unix epoch")
+		})
+
+		when("volumes are given", func() {
+			var hostDir string
+
+			it.Before(func() {
+				var err error
+				hostDir, err = ioutil.TempDir("", "pack.build.volume.")
+				assertNil(t, err)
+			})
+
+			it.After(func() {
+				os.RemoveAll(hostDir)
+			})
+
+			it("defaults them to read-write", func() {
+				mockDocker.EXPECT().PullImage("some/builder")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+				mockDocker.EXPECT().PullImage("some/run")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					Volumes:  []string{hostDir + ":/root/.m2", hostDir + ":/etc/ssl/certs/:ro"},
+				})
+				assertNil(t, err)
+				assertEq(t, config.Volumes, []string{hostDir + ":/root/.m2:rw", hostDir + ":/etc/ssl/certs:ro"})
+			})
+
+			it("rejects mounts into the workspace", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					Volumes:  []string{hostDir + ":/workspace/app/secrets:ro"},
+				})
+				assertError(t, err, fmt.Sprintf("invalid volume '%s:/workspace/app/secrets:ro': cannot mount into /workspace", hostDir))
+			})
+
+			it("rejects writable mounts over the cache and lifecycle", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					Volumes:  []string{hostDir + ":/lifecycle"},
+				})
+				assertError(t, err, fmt.Sprintf("invalid volume '%s:/lifecycle': /lifecycle can only be mounted read-only", hostDir))
+			})
+
+			it("rejects an invalid mode", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					RepoName: "some/app",
+					Builder:  "some/builder",
+					Volumes:  []string{hostDir + ":/data:rx"},
+				})
+				assertError(t, err, fmt.Sprintf("invalid volume '%s:/data:rx': mode must be 'ro' or 'rw'", hostDir))
+			})
+		})
+
+		when("the app is a git repository", func() {
+			var repoDir string
+
+			it.Before(func() {
+				var err error
+				repoDir, err = ioutil.TempDir("", "pack.build.git.")
+				assertNil(t, err)
+				assertNil(t, os.MkdirAll(filepath.Join(repoDir, "src", "app"), 0755))
+				assertNil(t, ioutil.WriteFile(filepath.Join(repoDir, "src", "app", "main.go"), []byte("package main"), 0644))
+				assertNil(t, os.Symlink("/", filepath.Join(repoDir, "root")))
+				assertNil(t, os.MkdirAll(filepath.Join(repoDir, "src", "leaky"), 0755))
+				assertNil(t, os.Symlink("/etc", filepath.Join(repoDir, "src", "leaky", "etc")))
+				for _, args := range [][]string{
+					{"init", "--quiet"},
+					{"add", "."},
+					{"-c", "user.name=pack", "-c", "user.email=pack@example.com", "commit", "--quiet", "-m", "initial"},
+					{"tag", "v1"},
+				} {
+					cmd := exec.Command("git", args...)
+					cmd.Dir = repoDir
+					if out, err := cmd.CombinedOutput(); err != nil {
+						t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+					}
+				}
+			})
+
+			it.After(func() {
+				os.RemoveAll(repoDir)
+			})
+
+			it("clones the ref and builds the subdirectory", func() {
+				mockDocker.EXPECT().PullImage("some/builder")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+				mockDocker.EXPECT().PullImage("some/run")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+
+				source := "git+file://" + repoDir + "#v1:src/app"
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   source,
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNil(t, err)
+				assertDirContainsFileWithContents(t, config.AppDir, "main.go", "package main")
+				if _, err := os.Stat(filepath.Join(config.AppDir, "..", "..", ".git")); !os.IsNotExist(err) {
+					t.Fatalf("expected .git to be removed from the fetched app, got: %v", err)
+				}
+				assertEq(t, config.CacheVolume, pack.CacheVolumeName("file://"+repoDir+"#v1:src/app"))
+				assertEq(t, config.AppSource, "file://"+repoDir+"#v1:src/app")
+			})
+
+			it("returns an error when the subdirectory does not exist", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   "git+file://" + repoDir + "#v1:missing",
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), "subdirectory 'missing' not found")
+			})
+
+			it("returns an error when the subdirectory is outside the repository", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   "git+file://" + repoDir + "#v1:../repo-other",
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), "invalid subdirectory '../repo-other'")
+			})
+
+			it("returns an error when the subdirectory is a symlink out of the repository", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   "git+file://" + repoDir + "#v1:root",
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), "invalid subdirectory 'root'")
+			})
+
+			it("returns an error when a symlink in the subdirectory leads outside of the app", func() {
+				_, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   "git+file://" + repoDir + "#v1:src/leaky",
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), "symlink 'etc' leads outside of the app")
+			})
+		})
+
+		when("the app is an archive", func() {
+			it("extracts the archive and builds its top level directory", func() {
+				tmpDir, err := ioutil.TempDir("", "pack.build.archive.")
+				assertNil(t, err)
+				defer os.RemoveAll(tmpDir)
+				assertNil(t, os.MkdirAll(filepath.Join(tmpDir, "src", "my-app"), 0755))
+				assertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "src", "my-app", "index.js"), []byte("console.log('hi')"), 0644))
+				archive := filepath.Join(tmpDir, "app.tgz")
+				assertNil(t, (&fs.FS{}).CreateTGZFile(archive, filepath.Join(tmpDir, "src"), ".", 0, 0))
+
+				mockDocker.EXPECT().PullImage("some/builder")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+				mockDocker.EXPECT().PullImage("some/run")
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/run").Return(dockertypes.ImageInspect{
+					Config: &dockercontainer.Config{
+						Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+					},
+				}, nil, nil)
+
+				factory.FS = &fs.FS{}
+				config, err := factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   archive,
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNil(t, err)
+				assertDirContainsFileWithContents(t, config.AppDir, "index.js", "console.log('hi')")
+				assertEq(t, config.CacheVolume, pack.CacheVolumeName(archive))
+				assertEq(t, config.AppSource, archive)
+			})
+
+			it("returns an error when a symlink in the archive leads outside of the app", func() {
+				tmpDir, err := ioutil.TempDir("", "pack.build.archive.")
+				assertNil(t, err)
+				defer os.RemoveAll(tmpDir)
+				assertNil(t, os.MkdirAll(filepath.Join(tmpDir, "src", "my-app"), 0755))
+				assertNil(t, os.Symlink(tmpDir, filepath.Join(tmpDir, "src", "my-app", "home")))
+				archive := filepath.Join(tmpDir, "app.tgz")
+				assertNil(t, (&fs.FS{}).CreateTGZFile(archive, filepath.Join(tmpDir, "src"), ".", 0, 0))
+
+				factory.FS = &fs.FS{}
+				_, err = factory.BuildConfigFromFlags(&pack.BuildFlags{
+					AppDir:   archive,
+					RepoName: "some/app",
+					Builder:  "some/builder",
+				})
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), "symlink 'my-app/home' leads outside of the app")
+			})
+		})
+
 		it("returns an errors when the builder stack label is missing", func() {
 			mockDocker.EXPECT().PullImage("some/builder")
 			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
@@ -265,9 +847,78 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#RunContext", func() {
+		var (
+			mockController *gomock.Controller
+			mockDocker     *mocks.MockDocker
+		)
+
+		it.Before(func() {
+			mockController = gomock.NewController(t)
+			mockDocker = mocks.NewMockDocker(mockController)
+			subject.Cli = mockDocker
+		})
+
+		it.After(func() {
+			mockController.Finish()
+		})
+
+		when("the context is cancelled", func() {
+			it("removes the in-flight container and the workspace volume", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				mockDocker.EXPECT().VolumeCreate(gomock.Any(), gomock.Any())
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").
+					Return(dockercontainer.ContainerCreateCreatedBody{ID: "some-detect-container"}, nil)
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), subject.Builder).Return(dockertypes.ImageInspect{}, nil, context.Canceled)
+				mockDocker.EXPECT().ContainerRemove(gomock.Any(), "some-detect-container", dockertypes.ContainerRemoveOptions{Force: true})
+				mockDocker.EXPECT().VolumeRemove(gomock.Any(), subject.WorkspaceVolume, true)
+
+				err := subject.RunContext(ctx)
+				assertError(t, err, "build interrupted: context canceled")
+			})
+		})
+
+		when("a network is given", func() {
+			it.Before(func() {
+				os.Setenv("https_proxy", "http://proxy.example.com:3128")
+			})
+
+			it.After(func() {
+				os.Unsetenv("https_proxy")
+			})
+
+			it("connects the detect container to it and passes on the proxy settings", func() {
+				subject.Network = "some-network"
+				var (
+					config     *dockercontainer.Config
+					hostConfig *dockercontainer.HostConfig
+				)
+				mockDocker.EXPECT().VolumeCreate(gomock.Any(), volume.VolumeCreateBody{
+					Name:   subject.WorkspaceVolume,
+					Labels: map[string]string{pack.ManagedLabel: "true"},
+				})
+				mockDocker.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), nil, "").
+					Do(func(_ context.Context, c *dockercontainer.Config, h *dockercontainer.HostConfig, _ interface{}, _ string) {
+						config, hostConfig = c, h
+					}).
+					Return(dockercontainer.ContainerCreateCreatedBody{ID: "some-detect-container"}, nil)
+				mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), subject.Builder).Return(dockertypes.ImageInspect{}, nil, errors.New("stop here"))
+				mockDocker.EXPECT().ContainerRemove(gomock.Any(), "some-detect-container", gomock.Any())
+				mockDocker.EXPECT().VolumeRemove(gomock.Any(), subject.WorkspaceVolume, true)
+
+				assertNotNil(t, subject.RunContext(context.Background()))
+				assertEq(t, string(hostConfig.NetworkMode), "some-network")
+				assertEq(t, config.Labels, map[string]string{pack.ManagedLabel: "true"})
+				assertContains(t, strings.Join(config.Env, "\n"), "https_proxy=http://proxy.example.com:3128")
+			})
+		})
+	})
+
 	when("#Detect", func() {
-		it("copies the app in to docker and chowns it (including directories)", func() {
-			_, err := subject.Detect()
+		it("copies the app in to docker owned by the pack user (including directories)", func() {
+			_, err := subject.Detect(context.Background())
 			assertNil(t, err)
 
 			for _, name := range []string{"/workspace/app", "/workspace/app/app.js", "/workspace/app/mydir", "/workspace/app/mydir/myfile.txt"} {
@@ -277,9 +928,122 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		when("the app is kept between builds", func() {
+			var appDir, configDir string
+			it.Before(func() {
+				var err error
+				appDir, err = ioutil.TempDir("/tmp", "pack.build.app.")
+				assertNil(t, err)
+				assertNil(t, exec.Command("cp", "-r", "acceptance/testdata/node_app/.", appDir).Run())
+				assertNil(t, ioutil.WriteFile(filepath.Join(appDir, "old.txt"), []byte("old"), 0644))
+				configDir, err = ioutil.TempDir("/tmp", "pack.build.config.")
+				assertNil(t, err)
+
+				subject.AppDir = appDir
+				subject.AppVolume = fmt.Sprintf("pack-app-%x", uuid.New().String())
+				subject.Config, err = config.New(configDir)
+				assertNil(t, err)
+				subject.Log = logging.New(&buf, &buf, logging.Options{Verbose: true})
+			})
+			it.After(func() {
+				os.RemoveAll(appDir)
+				os.RemoveAll(configDir)
+				exec.Command("docker", "volume", "rm", "-f", subject.AppVolume).Run()
+			})
+
+			lsApp := func() string {
+				t.Helper()
+				txt, err := exec.Command("docker", "run", "--rm", "-v", subject.AppVolume+":/workspace/app", subject.Builder, "ls", "-A", "/workspace/app").Output()
+				assertNil(t, err)
+				return string(txt)
+			}
+
+			it("only copies what changed and deletes what was removed", func() {
+				_, err := subject.Detect(context.Background())
+				assertNil(t, err)
+				assertContains(t, lsApp(), "old.txt\n")
+
+				assertNil(t, ioutil.WriteFile(filepath.Join(appDir, "new.txt"), []byte("new"), 0644))
+				assertNil(t, os.Remove(filepath.Join(appDir, "old.txt")))
+				subject.WorkspaceVolume = fmt.Sprintf("pack-workspace-%x", uuid.New().String())
+				defer exec.Command("docker", "volume", "rm", "-f", subject.WorkspaceVolume).Run()
+				buf.Reset()
+				_, err = subject.Detect(context.Background())
+				assertNil(t, err)
+				assertContains(t, buf.String(), "Copied 1 changed and removed 1 deleted app files")
+
+				files := lsApp()
+				assertContains(t, files, "new.txt\n")
+				assertContains(t, files, "app.js\n")
+				if strings.Contains(files, "old.txt") {
+					t.Fatalf("expected old.txt to be removed from the app volume, got %s", files)
+				}
+				txt, err := exec.Command("docker", "run", "--rm", "-v", subject.WorkspaceVolume+":/workspace", subject.Builder, "ls", "-A", "/workspace/app").Output()
+				assertNil(t, err)
+				assertContains(t, string(txt), "new.txt\n")
+				if strings.Contains(string(txt), "old.txt") {
+					t.Fatalf("expected old.txt to be removed from the workspace, got %s", txt)
+				}
+			})
+
+			it("keeps what a buildpack does to the app dir out of the next build", func() {
+				bpDir, err := ioutil.TempDir("", "pack.build.buildpack.")
+				assertNil(t, err)
+				defer os.RemoveAll(bpDir)
+				assertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "buildpack.toml"), []byte(`
+[buildpack]
+id = "some.local.bp"
+version = "1.2.3"
+name = "Some Local Buildpack"
+`), 0644))
+				assertNil(t, os.Mkdir(filepath.Join(bpDir, "bin"), 0755))
+				assertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bin", "detect"), []byte("#!/usr/bin/env bash\nexit 0\n"), 0755))
+				assertNil(t, ioutil.WriteFile(filepath.Join(bpDir, "bin", "build"), []byte(`#!/usr/bin/env bash
+set -e
+if [[ -e built.txt ]]; then echo "leftover built.txt"; exit 1; fi
+if [[ ! -e app.js ]]; then echo "app.js missing"; exit 1; fi
+echo built > built.txt
+rm app.js
+`), 0755))
+				subject.Buildpacks = []string{"some.local.bp@1.2.3"}
+				subject.LocalBuildpacks = []pack.LocalBuildpack{{ID: "some.local.bp", Version: "1.2.3", Dir: bpDir}}
+				defer exec.Command("docker", "volume", "rm", "-f", subject.CacheVolume).Run()
+
+				for i := 0; i < 2; i++ {
+					if i > 0 {
+						subject.WorkspaceVolume = fmt.Sprintf("pack-workspace-%x", uuid.New().String())
+					}
+					defer exec.Command("docker", "volume", "rm", "-f", subject.WorkspaceVolume).Run()
+					_, err := subject.Detect(context.Background())
+					assertNil(t, err)
+					if err := subject.Build(context.Background()); err != nil {
+						t.Fatalf("build %d failed: %s: %s", i+1, err, buf.String())
+					}
+				}
+
+				files := lsApp()
+				assertContains(t, files, "app.js\n")
+				if strings.Contains(files, "built.txt") {
+					t.Fatalf("expected the app volume to only hold the app, got %s", files)
+				}
+			})
+
+			it("starts over when the app volume was removed", func() {
+				_, err := subject.Detect(context.Background())
+				assertNil(t, err)
+				assertNil(t, exec.Command("docker", "volume", "rm", subject.WorkspaceVolume, subject.AppVolume).Run())
+
+				buf.Reset()
+				_, err = subject.Detect(context.Background())
+				assertNil(t, err)
+				assertContains(t, buf.String(), "Copying the whole app to a new app volume")
+				assertContains(t, lsApp(), "app.js\n")
+			})
+		})
+
 		when("app is detected", func() {
 			it("returns the successful group with node", func() {
-				group, err := subject.Detect()
+				group, err := subject.Detect(context.Background())
 				assertNil(t, err)
 				assertEq(t, group.Buildpacks[0].ID, "io.buildpacks.samples.nodejs")
 			})
@@ -296,7 +1060,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			})
 			it.After(func() { os.RemoveAll(badappDir) })
 			it("returns the successful group with node", func() {
-				_, err := subject.Detect()
+				_, err := subject.Detect(context.Background())
 
 				assertNotNil(t, err)
 				assertEq(t, err.Error(), "run detect container: failed with status code: 6")
@@ -327,15 +1091,15 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 				it.After(func() { assertNil(t, exec.Command("docker", "kill", registryContainerName).Run()) })
 
 				it("informs the user", func() {
-					err := subject.Analyze()
+					err := subject.Analyze(context.Background())
 					assertNil(t, err)
-					assertContains(t, buf.String(), "WARNING: skipping analyze, image not found or requires authentication to access")
+					assertContains(t, buf.String(), "WARNING: skipping analyze, image not found\n")
 				})
 			})
 			when("daemon", func() {
 				it.Before(func() { subject.Publish = false })
 				it("informs the user", func() {
-					err := subject.Analyze()
+					err := subject.Analyze(context.Background())
 					assertNil(t, err)
 					assertContains(t, buf.String(), "WARNING: skipping analyze, image not found\n")
 				})
@@ -369,14 +1133,14 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 				})
 
 				it("tells the user nothing", func() {
-					assertNil(t, subject.Analyze())
+					assertNil(t, subject.Analyze(context.Background()))
 
 					txt := string(bytes.Trim(buf.Bytes(), "\x00"))
 					assertEq(t, txt, "")
 				})
 
 				it("places files in workspace", func() {
-					assertNil(t, subject.Analyze())
+					assertNil(t, subject.Analyze(context.Background()))
 
 					txt := readFromDocker(t, subject.WorkspaceVolume, "/workspace/io.buildpacks.samples.nodejs/node_modules.toml")
 
@@ -388,14 +1152,14 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 				it.Before(func() { subject.Publish = false })
 
 				it("tells the user nothing", func() {
-					assertNil(t, subject.Analyze())
+					assertNil(t, subject.Analyze(context.Background()))
 
 					txt := string(bytes.Trim(buf.Bytes(), "\x00"))
 					assertEq(t, txt, "")
 				})
 
 				it("places files in workspace", func() {
-					assertNil(t, subject.Analyze())
+					assertNil(t, subject.Analyze(context.Background()))
 
 					txt := readFromDocker(t, subject.WorkspaceVolume, "/workspace/io.buildpacks.samples.nodejs/node_modules.toml")
 					assertEq(t, txt, "lock_checksum = \"eb04ed1b461f1812f0f4233ef997cdb5\"\n")
@@ -404,6 +1168,71 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#ExportCache", func() {
+		var registryContainerName, registryPort string
+		var cacheVolumes []string
+		it.Before(func() {
+			registryContainerName, registryPort = runRegistry(t)
+			subject.CacheImage = "localhost:" + registryPort + "/pack.cache." + randString(10)
+
+			tmpDir, err := ioutil.TempDir("/tmp", "pack.build.cache.")
+			assertNil(t, err)
+			defer os.RemoveAll(tmpDir)
+			files := map[string]string{
+				"io.buildpacks.samples.nodejs/node_modules.toml":     `lock_checksum = "eb04ed1b461f1812f0f4233ef997cdb5"`,
+				"io.buildpacks.samples.nodejs/node_modules/file.txt": "modules",
+				"io.buildpacks.samples.nodejs/nodejs/file.txt":       "node",
+			}
+			for name, txt := range files {
+				assertNil(t, os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, name)), 0777))
+				assertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(txt), 0666))
+			}
+			copyCacheToDocker(t, tmpDir, subject.CacheVolume)
+			cacheVolumes = []string{subject.CacheVolume}
+		})
+		it.After(func() {
+			assertNil(t, exec.Command("docker", "kill", registryContainerName).Run())
+			for _, volume := range cacheVolumes {
+				exec.Command("docker", "volume", "rm", "-f", volume).Run()
+			}
+		})
+
+		it("can be restored into an empty cache volume", func() {
+			assertNil(t, subject.ExportCache(context.Background()))
+
+			subject.CacheVolume = fmt.Sprintf("pack-cache-%x", uuid.New().String())
+			cacheVolumes = append(cacheVolumes, subject.CacheVolume)
+			assertNil(t, subject.RestoreCache(context.Background()))
+
+			txt := readFromDocker(t, subject.CacheVolume, "/workspace/io.buildpacks.samples.nodejs/node_modules/file.txt")
+			assertEq(t, txt, "modules")
+			txt = readFromDocker(t, subject.CacheVolume, "/workspace/io.buildpacks.samples.nodejs/node_modules.toml")
+			assertEq(t, txt, `lock_checksum = "eb04ed1b461f1812f0f4233ef997cdb5"`)
+			txt = readFromDocker(t, subject.CacheVolume, "/workspace/io.buildpacks.samples.nodejs/nodejs/file.txt")
+			assertEq(t, txt, "node")
+		})
+
+		it("only exports the layers that changed", func() {
+			assertNil(t, subject.ExportCache(context.Background()))
+			assertContains(t, buf.String(), "2 of 2 layers changed")
+
+			cmd := exec.Command("docker", "run", "--rm", "--user", "root", "-v", subject.CacheVolume+":/cache", "packs/samples",
+				"sh", "-c", "echo changed > /cache/io.buildpacks.samples.nodejs/nodejs/file.txt")
+			assertNil(t, cmd.Run())
+			buf.Reset()
+			assertNil(t, subject.ExportCache(context.Background()))
+			assertContains(t, buf.String(), "1 of 2 layers changed")
+		})
+
+		it("leaves the cache as it is when there is no cache image yet", func() {
+			assertNil(t, subject.RestoreCache(context.Background()))
+			assertContains(t, buf.String(), "not found, starting with an empty cache")
+
+			txt := readFromDocker(t, subject.CacheVolume, "/workspace/io.buildpacks.samples.nodejs/nodejs/file.txt")
+			assertEq(t, txt, "node")
+		})
+	})
+
 	when("#Export", func() {
 		var group *lifecycle.BuildpackGroup
 		it.Before(func() {
@@ -446,12 +1275,12 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					assertNil(t, exec.Command("docker", "kill", registryContainerName).Run())
 				})
 				it("creates the image on the registry", func() {
-					assertNil(t, subject.Export(group))
+					assertNil(t, subject.Export(context.Background(), group))
 					images := httpGet(t, "http://localhost:"+registryPort+"/v2/_catalog")
 					assertContains(t, images, oldRepoName)
 				})
 				it("puts the files on the image", func() {
-					assertNil(t, subject.Export(group))
+					assertNil(t, subject.Export(context.Background(), group))
 
 					assertNil(t, exec.Command("docker", "pull", subject.RepoName).Run())
 					txt, err := exec.Command("docker", "run", subject.RepoName, "cat", "/workspace/app/file.txt").Output()
@@ -463,7 +1292,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					assertEq(t, string(txt), "content")
 				})
 				it("sets the metadata on the image", func() {
-					assertNil(t, subject.Export(group))
+					assertNil(t, subject.Export(context.Background(), group))
 
 					assertNil(t, exec.Command("docker", "pull", subject.RepoName).Run())
 					var metadata lifecycle.AppImageMetadata
@@ -471,6 +1300,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					assertNil(t, err)
 					assertNil(t, json.Unmarshal(metadataJSON, &metadata))
 
+					assertEq(t, metadata.RunImage.Name, "packs/run")
 					assertContains(t, metadata.App.SHA, "sha256:")
 					assertContains(t, metadata.Config.SHA, "sha256:")
 					assertEq(t, len(metadata.Buildpacks), 1)
@@ -483,13 +1313,13 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 			when("daemon", func() {
 				it.Before(func() { subject.Publish = false })
 				it("creates the image on the daemon", func() {
-					assertNil(t, subject.Export(group))
+					assertNil(t, subject.Export(context.Background(), group))
 					images, err := exec.Command("docker", "images", "--format", "{{.Repository}}:{{.Tag}}").Output()
 					assertNil(t, err)
 					assertContains(t, string(images), subject.RepoName)
 				})
 				it("puts the files on the image", func() {
-					assertNil(t, subject.Export(group))
+					assertNil(t, subject.Export(context.Background(), group))
 
 					txt, err := exec.Command("docker", "run", subject.RepoName, "cat", "/workspace/app/file.txt").Output()
 					assertNil(t, err)
@@ -500,7 +1330,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					assertEq(t, string(txt), "content")
 				})
 				it("sets the metadata on the image", func() {
-					assertNil(t, subject.Export(group))
+					assertNil(t, subject.Export(context.Background(), group))
 
 					var metadata lifecycle.AppImageMetadata
 					metadataJSON, err := exec.Command("docker", "inspect", subject.RepoName, "--format", `{{index .Config.Labels "io.buildpacks.lifecycle.metadata"}}`).Output()
@@ -516,24 +1346,87 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					assertContains(t, metadata.Buildpacks[0].Layers["other"].SHA, "sha256:")
 				})
 			})
+
+			when("reproducible", func() {
+				it.Before(func() {
+					subject.Publish = false
+					subject.Reproducible = true
+					subject.Env = map[string]string{"SOURCE_DATE_EPOCH": "1500000000"}
+				})
+
+				it("creates the same image twice", func() {
+					assertNil(t, subject.Export(context.Background(), group))
+					firstID, err := exec.Command("docker", "inspect", subject.RepoName, "--format", "{{.Id}}").Output()
+					assertNil(t, err)
+					assertNil(t, exec.Command("docker", "rmi", subject.RepoName).Run())
+
+					assertNil(t, subject.Export(context.Background(), group))
+					secondID, err := exec.Command("docker", "inspect", subject.RepoName, "--format", "{{.Id}}").Output()
+					assertNil(t, err)
+					assertEq(t, string(secondID), string(firstID))
+
+					created, err := exec.Command("docker", "inspect", subject.RepoName, "--format", "{{.Created}}").Output()
+					assertNil(t, err)
+					assertContains(t, string(created), "2017-07-14T02:40:00Z")
+				})
+			})
+
+			when("a default process is given", func() {
+				it.Before(func() {
+					tmpDir, err := ioutil.TempDir("/tmp", "pack.build.export.")
+					assertNil(t, err)
+					defer os.RemoveAll(tmpDir)
+					assertNil(t, os.MkdirAll(filepath.Join(tmpDir, "io.buildpacks.samples.nodejs"), 0777))
+					assertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "io.buildpacks.samples.nodejs", "launch.toml"), []byte(`
+[[processes]]
+type = "web"
+command = "npm start"
+
+[[processes]]
+type = "worker"
+command = "npm run worker"
+`), 0666))
+					copyWorkspaceToDocker(t, tmpDir, subject.WorkspaceVolume)
+					subject.Publish = false
+				})
+
+				it("makes it the process the image starts", func() {
+					subject.DefaultProcess = "worker"
+					assertNil(t, subject.Export(context.Background(), group))
+
+					env, err := exec.Command("docker", "inspect", subject.RepoName, "--format", "{{range .Config.Env}}{{println .}}{{end}}").Output()
+					assertNil(t, err)
+					assertContains(t, string(env), "PACK_PROCESS_TYPE=worker\n")
+				})
+
+				it("returns an error listing the available types for an unknown one", func() {
+					subject.DefaultProcess = "console"
+					err := subject.Export(context.Background(), group)
+					assertError(t, err, "process type 'console' not found, available types: web, worker")
+				})
+			})
 		})
 
 		when("previous image exists", func() {
 			it("reuses images from previous layers", func() {
-				addLayer := "ADD --chown=pack:pack /workspace/io.buildpacks.samples.nodejs/mylayer /workspace/io.buildpacks.samples.nodejs/mylayer"
-				copyLayer := "COPY --from=prev --chown=pack:pack /workspace/io.buildpacks.samples.nodejs/mylayer /workspace/io.buildpacks.samples.nodejs/mylayer"
+				var events bytes.Buffer
+				subject.Events = pack.NewJSONEvents(&events)
 
 				t.Log("create image and assert add new layer")
-				assertNil(t, subject.Export(group))
-				assertContains(t, buf.String(), addLayer)
+				assertNil(t, subject.Export(context.Background(), group))
+				assertContains(t, events.String(), `"layer":"mylayer","reused":false`)
 
 				t.Log("setup workspace to reuse layer")
-				buf.Reset()
+				events.Reset()
 				assertNil(t, exec.Command("docker", "run", "--user=root", "-v", subject.WorkspaceVolume+":/workspace", "packs/samples", "rm", "-rf", "/workspace/io.buildpacks.samples.nodejs/mylayer").Run())
 
 				t.Log("recreate image and assert copying layer from previous image")
-				assertNil(t, subject.Export(group))
-				assertContains(t, buf.String(), copyLayer)
+				assertNil(t, subject.Export(context.Background(), group))
+				assertContains(t, events.String(), `"layer":"mylayer","reused":true`)
+
+				txt, err := exec.Command("docker", "run", subject.RepoName, "cat", "/workspace/io.buildpacks.samples.nodejs/mylayer/file.txt").Output()
+				assertNil(t, err)
+				assertEq(t, string(txt), "content")
 			})
 		})
 	})
@@ -633,6 +1526,14 @@ func copyWorkspaceToDocker(t *testing.T, srcPath, destVolume string) {
 	assertNil(t, exec.Command("docker", "cp", srcPath+"/.", ctrName+":/workspace/").Run())
 }
 
+func copyCacheToDocker(t *testing.T, srcPath, destVolume string) {
+	t.Helper()
+	ctrName := uuid.New().String()
+	defer exec.Command("docker", "rm", ctrName).Run()
+	assertNil(t, exec.Command("docker", "create", "--name", ctrName, "-v", destVolume+":/cache", "packs/samples", "true").Run())
+	assertNil(t, exec.Command("docker", "cp", srcPath+"/.", ctrName+":/cache/").Run())
+}
+
 func readFromDocker(t *testing.T, volume, path string) string {
 	t.Helper()
 
@@ -0,0 +1,121 @@
+package pack
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/docker/docker/api/types/filters"
+	dockercli "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+const cacheVolumePrefix = "pack-cache-"
+
+type CacheFactory struct {
+	Log    logging.Logger
+	Docker Docker
+	Config *config.Config
+}
+
+type ClearCacheFlags struct {
+	AppDir    string
+	OlderThan time.Duration
+}
+
+type CacheVolume struct {
+	Name     string
+	AppPath  string
+	Size     int64 // -1 when the daemon did not report a size
+	LastUsed time.Time
+}
+
+func CacheVolumeName(appDir string) string {
+	return fmt.Sprintf("%s%x", cacheVolumePrefix, md5.Sum([]byte(appDir)))
+}
+
+func (f *CacheFactory) List() ([]CacheVolume, error) {
+	ctx := context.Background()
+	vols, err := f.Docker.VolumeList(ctx, filters.NewArgs(filters.Arg("name", cacheVolumePrefix)))
+	if err != nil {
+		return nil, errors.Wrap(err, "list cache volumes")
+	}
+
+	sizes := map[string]int64{}
+	du, err := f.Docker.DiskUsage(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "read volume sizes")
+	}
+	for _, v := range du.Volumes {
+		if v.UsageData != nil {
+			sizes[v.Name] = v.UsageData.Size
+		}
+	}
+
+	var caches []CacheVolume
+	for _, v := range vols.Volumes {
+		if !strings.HasPrefix(v.Name, cacheVolumePrefix) {
+			continue
+		}
+		cache := CacheVolume{Name: v.Name, Size: -1}
+		if size, ok := sizes[v.Name]; ok {
+			cache.Size = size
+		}
+		if c := f.Config.GetCache(v.Name); c != nil {
+			cache.AppPath = c.AppPath
+			cache.LastUsed = c.LastUsed
+		}
+		caches = append(caches, cache)
+	}
+	sort.Slice(caches, func(i, j int) bool { return caches[i].LastUsed.After(caches[j].LastUsed) })
+	return caches, nil
+}
+
+// Clear removes the cache volume for flags.AppDir, or every cache volume when
+// no app dir is given. With OlderThan set, only caches not used within that
+// duration are removed; caches pack has no record of count as stale.
+func (f *CacheFactory) Clear(flags ClearCacheFlags) ([]string, error) {
+	var names []string
+	if flags.AppDir != "" {
+		source, err := parseSource(flags.AppDir)
+		if err != nil {
+			return nil, err
+		}
+		name := CacheVolumeName(source.Identity())
+		if _, err := f.Docker.VolumeInspect(context.Background(), name); dockercli.IsErrNotFound(err) {
+			return nil, fmt.Errorf(`no cache found for app "%s"`, source.Identity())
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "inspect cache volume %s", name)
+		}
+		names = append(names, name)
+	} else {
+		caches, err := f.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range caches {
+			if flags.OlderThan > 0 && !c.LastUsed.IsZero() && time.Since(c.LastUsed) < flags.OlderThan {
+				continue
+			}
+			names = append(names, c.Name)
+		}
+	}
+
+	var removed []string
+	for _, name := range names {
+		if err := f.Docker.VolumeRemove(context.Background(), name, false); err != nil {
+			return removed, errors.Wrapf(err, "remove cache volume %s", name)
+		}
+		if err := f.Config.DeleteCache(name); err != nil {
+			return removed, err
+		}
+		f.Log.Info("Removed cache volume %s", name)
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,268 @@
+package pack
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+)
+
+// CacheImageLabel maps each buildpack layer of the cache, keyed
+// "<buildpack ID>/<layer>", to the diff ID of the cache image layer holding it.
+const CacheImageLabel = "io.buildpacks.pack.cache"
+
+// RestoreCache fills the cache volume from the layers of CacheImage. A cache
+// image that does not exist yet leaves the cache as it is.
+func (b *BuildConfig) RestoreCache(ctx context.Context) error {
+	cacheImage, err := b.Images.ReadImage(b.CacheImage, false)
+	if err != nil {
+		return errors.Wrap(err, "read cache image")
+	}
+	if cacheImage == nil {
+		b.Log.Info("Cache image '%s' not found, starting with an empty cache", b.CacheImage)
+		return nil
+	}
+	keys, err := cacheImageKeys(cacheImage)
+	if err != nil {
+		return err
+	}
+	layers, err := cacheImage.Layers()
+	if err != nil {
+		return errors.Wrap(err, "read cache image layers")
+	}
+
+	ctr, err := b.cacheContainer(ctx, false)
+	if err != nil {
+		return errors.Wrap(err, "restore cache container create")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	for _, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return err
+		}
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return errors.Wrapf(err, "read cache layer '%s'", keys[diffID.String()])
+		}
+		err = b.Cli.CopyToContainer(ctx, ctr.ID, "/", rc, dockertypes.CopyToContainerOptions{})
+		rc.Close()
+		if err != nil {
+			return errors.Wrapf(err, "restore cache layer '%s'", keys[diffID.String()])
+		}
+		b.Log.Verbose("Restored cache layer '%s'", keys[diffID.String()])
+	}
+	return nil
+}
+
+// ExportCache writes the cache volume to CacheImage, one layer per buildpack
+// layer. Layers that did not change are reused from the previous cache image,
+// so only the changed ones are pushed.
+func (b *BuildConfig) ExportCache(ctx context.Context) error {
+	previous, err := b.Images.ReadImage(b.CacheImage, false)
+	if err != nil {
+		return errors.Wrap(err, "read cache image")
+	}
+	previousKeys := map[string]string{}
+	if previous != nil {
+		if previousKeys, err = cacheImageKeys(previous); err != nil {
+			return err
+		}
+	}
+
+	ctr, err := b.cacheContainer(ctx, true)
+	if err != nil {
+		return errors.Wrap(err, "export cache container create")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	r, _, err := b.Cli.CopyFromContainer(ctx, ctr.ID, "/cache")
+	if err != nil {
+		return errors.Wrap(err, "read cache volume")
+	}
+	defer r.Close()
+
+	tmpDir, err := ioutil.TempDir("", "pack.cache.")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	files, err := splitCacheLayers(r, tmpDir)
+	if err != nil {
+		return errors.Wrap(err, "split cache into layers")
+	}
+
+	var keys []string
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var layers []v1.Layer
+	metadata := map[string]string{}
+	changed := 0
+	for _, key := range keys {
+		layer, err := tarball.LayerFromFile(files[key])
+		if err != nil {
+			return err
+		}
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return err
+		}
+		if previousKeys[key] == diffID.String() {
+			if layer, err = previous.LayerByDiffID(diffID); err != nil {
+				return err
+			}
+			b.Log.Verbose("Reusing cache layer '%s'", key)
+		} else {
+			b.Log.Verbose("Exporting cache layer '%s'", key)
+			changed++
+		}
+		layers = append(layers, layer)
+		metadata[key] = diffID.String()
+	}
+
+	label, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	cacheImage, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		return err
+	}
+	cacheImage, err = mutate.Config(cacheImage, v1.Config{Labels: map[string]string{CacheImageLabel: string(label)}})
+	if err != nil {
+		return err
+	}
+	store, err := b.Images.RepoStore(b.CacheImage, false)
+	if err != nil {
+		return err
+	}
+	if err := store.Write(cacheImage); err != nil {
+		return errors.Wrapf(err, "write cache image '%s'", b.CacheImage)
+	}
+	b.Log.Info("Exported cache to '%s', %d of %d layers changed", b.CacheImage, changed, len(layers))
+	return nil
+}
+
+func (b *BuildConfig) cacheContainer(ctx context.Context, readOnly bool) (container.ContainerCreateCreatedBody, error) {
+	bind := b.CacheVolume + ":/cache"
+	if readOnly {
+		bind += ":ro"
+	}
+	return b.Cli.ContainerCreate(ctx, &container.Config{
+		Image:  b.Builder,
+		Cmd:    []string{"true"},
+		Labels: managedLabels(),
+	}, &container.HostConfig{
+		Binds: []string{bind},
+	}, nil, "")
+}
+
+// cacheImageKeys maps the diff IDs in the CacheImageLabel of a cache image
+// back to their buildpack layer keys.
+func cacheImageKeys(cacheImage v1.Image) (map[string]string, error) {
+	config, err := cacheImage.ConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "read cache image config")
+	}
+	var metadata map[string]string
+	if label := config.Config.Labels[CacheImageLabel]; label != "" {
+		if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+			return nil, errors.Wrap(err, "parse cache image label")
+		}
+	}
+	keys := map[string]string{}
+	for key, diffID := range metadata {
+		keys[diffID] = key
+	}
+	return keys, nil
+}
+
+// splitCacheLayers splits a tar of /cache into a tar file in dir per buildpack
+// layer, keeping the headers as they are so unchanged layers come out the
+// same. Each layer also gets the directory of its buildpack, so it is
+// restored with the right owner. Anything outside a buildpack layer is left
+// out.
+func splitCacheLayers(r io.Reader, dir string) (map[string]string, error) {
+	type layerFile struct {
+		f  *os.File
+		tw *tar.Writer
+	}
+	open := map[string]*layerFile{}
+	defer func() {
+		for _, l := range open {
+			l.f.Close()
+		}
+	}()
+	bpDirs := map[string]*tar.Header{}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		// cache/<buildpack ID>/<layer>[.toml][/...]
+		parts := strings.SplitN(strings.Trim(hdr.Name, "/"), "/", 4)
+		if len(parts) < 2 {
+			continue
+		}
+		if len(parts) == 2 {
+			if hdr.Typeflag == tar.TypeDir {
+				bpDir := *hdr
+				bpDirs[parts[1]] = &bpDir
+			}
+			continue
+		}
+		key := parts[1] + "/" + strings.TrimSuffix(parts[2], ".toml")
+
+		l, ok := open[key]
+		if !ok {
+			f, err := os.Create(filepath.Join(dir, strconv.Itoa(len(open))+".tar"))
+			if err != nil {
+				return nil, err
+			}
+			l = &layerFile{f: f, tw: tar.NewWriter(f)}
+			open[key] = l
+			if bpDir, ok := bpDirs[parts[1]]; ok {
+				if err := l.tw.WriteHeader(bpDir); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := l.tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(l.tw, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	files := map[string]string{}
+	for key, l := range open {
+		if err := l.tw.Close(); err != nil {
+			return nil, err
+		}
+		files[key] = l.f.Name()
+	}
+	return files, nil
+}
@@ -0,0 +1,118 @@
+package pack_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestCache(t *testing.T) {
+	spec.Run(t, "cache", testCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+		mockDocker     *mocks.MockDocker
+		factory        pack.CacheFactory
+		tmpDir         string
+		lastUsed       time.Time
+	)
+
+	it.Before(func() {
+		var err error
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+
+		tmpDir, err = ioutil.TempDir("", "pack.cache.test.")
+		assertNil(t, err)
+		cfg, err := config.New(tmpDir)
+		assertNil(t, err)
+		lastUsed = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		assertNil(t, cfg.TouchCache(pack.CacheVolumeName("/some/app"), "/some/app", lastUsed))
+
+		factory = pack.CacheFactory{
+			Log:    logging.New(ioutil.Discard, ioutil.Discard, logging.Options{}),
+			Docker: mockDocker,
+			Config: cfg,
+		}
+
+		mockDocker.EXPECT().VolumeList(gomock.Any(), gomock.Any()).Return(volume.VolumeListOKBody{
+			Volumes: []*dockertypes.Volume{
+				{Name: pack.CacheVolumeName("/some/app")},
+				{Name: "pack-cache-unknown"},
+				{Name: "other-volume"},
+			},
+		}, nil).AnyTimes()
+		mockDocker.EXPECT().DiskUsage(gomock.Any()).Return(dockertypes.DiskUsage{
+			Volumes: []*dockertypes.Volume{
+				{Name: pack.CacheVolumeName("/some/app"), UsageData: &dockertypes.VolumeUsageData{Size: 1234}},
+			},
+		}, nil).AnyTimes()
+	})
+
+	it.After(func() {
+		mockController.Finish()
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#List", func() {
+		it("returns pack cache volumes with their app path, size and last use", func() {
+			caches, err := factory.List()
+			assertNil(t, err)
+
+			assertEq(t, len(caches), 2)
+			assertEq(t, caches[0].Name, pack.CacheVolumeName("/some/app"))
+			assertEq(t, caches[0].AppPath, "/some/app")
+			assertEq(t, caches[0].Size, int64(1234))
+			assertEq(t, caches[0].LastUsed.Equal(lastUsed), true)
+			assertEq(t, caches[1].Name, "pack-cache-unknown")
+			assertEq(t, caches[1].AppPath, "")
+			assertEq(t, caches[1].Size, int64(-1))
+		})
+	})
+
+	when("#Clear", func() {
+		it("removes all cache volumes", func() {
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), pack.CacheVolumeName("/some/app"), false)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-cache-unknown", false)
+
+			removed, err := factory.Clear(pack.ClearCacheFlags{})
+			assertNil(t, err)
+			assertEq(t, len(removed), 2)
+			assertEq(t, factory.Config.GetCache(pack.CacheVolumeName("/some/app")) == nil, true)
+		})
+
+		it("only removes stale cache volumes with --older-than", func() {
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-cache-unknown", false)
+
+			removed, err := factory.Clear(pack.ClearCacheFlags{OlderThan: 24 * time.Hour})
+			assertNil(t, err)
+			assertEq(t, removed, []string{"pack-cache-unknown"})
+		})
+
+		it("removes the cache volume for an app dir", func() {
+			appDir, err := filepath.Abs("acceptance/testdata/node_app")
+			assertNil(t, err)
+			name := pack.CacheVolumeName(appDir)
+			mockDocker.EXPECT().VolumeInspect(gomock.Any(), name).Return(dockertypes.Volume{Name: name}, nil)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), name, false)
+
+			removed, err := factory.Clear(pack.ClearCacheFlags{AppDir: "acceptance/testdata/node_app"})
+			assertNil(t, err)
+			assertEq(t, removed, []string{name})
+		})
+	})
+}
@@ -1,31 +1,54 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/buildpack/pack"
 	"github.com/buildpack/pack/config"
 	"github.com/buildpack/pack/docker"
 	"github.com/buildpack/pack/fs"
 	"github.com/buildpack/pack/image"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/packs"
 	"github.com/spf13/cobra"
 )
 
-var Version = "UNKNOWN"
+var (
+	Version    = "UNKNOWN"
+	logOptions logging.Options
+)
 
 func main() {
-	rootCmd := &cobra.Command{Use: "pack"}
+	rootCmd := &cobra.Command{
+		Use:           "pack",
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if logOptions.Quiet && logOptions.Verbose {
+				return fmt.Errorf("--quiet cannot be combined with --verbose")
+			}
+			return nil
+		},
+	}
+	rootCmd.PersistentFlags().BoolVarP(&logOptions.Quiet, "quiet", "q", false, "only show warnings, errors and results")
+	rootCmd.PersistentFlags().BoolVarP(&logOptions.Verbose, "verbose", "v", false, "show more detail")
+	rootCmd.PersistentFlags().BoolVar(&logOptions.Timestamps, "timestamps", false, "start every line of output with the time")
+	rootCmd.PersistentFlags().BoolVar(&logOptions.NoColor, "no-color", false, "don't color output")
 	for _, f := range [](func() *cobra.Command){
 		buildCommand,
 		runCommand,
+		detectCommand,
 		rebaseCommand,
 		createBuilderCommand,
+		cacheCommand,
+		pruneCommand,
 		addStackCommand,
 		updateStackCommand,
 		deleteStackCommand,
@@ -35,35 +58,65 @@ func main() {
 		rootCmd.AddCommand(f())
 	}
 	if err := rootCmd.Execute(); err != nil {
+		newLogger().Error("%s", err)
+		if err == pack.ErrNoGroupDetected {
+			os.Exit(packs.CodeFailedDetect)
+		}
 		os.Exit(1)
 	}
 }
 
 func buildCommand() *cobra.Command {
 	var buildFlags pack.BuildFlags
+	var output string
+	var insecureRegistries []string
 	buildCommand := &cobra.Command{
-		Use:  "build <image-name>",
-		Args: cobra.MinimumNArgs(1),
+		Use:  "build [<image-name>]",
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
-			buildFlags.RepoName = args[0]
-			bf, err := pack.DefaultBuildFactory()
+			if len(args) > 0 {
+				buildFlags.RepoName = args[0]
+			}
+			bf, err := pack.DefaultBuildFactory(newLogger())
 			if err != nil {
 				return err
 			}
+			useInsecureRegistries(bf.Config, insecureRegistries)
+			switch output {
+			case "text":
+			case "json":
+				bf.UseJSONOutput(os.Stdout)
+			default:
+				return fmt.Errorf(`invalid output format "%s": must be one of "text" or "json"`, output)
+			}
 			b, err := bf.BuildConfigFromFlags(&buildFlags)
 			if err != nil {
 				return err
 			}
-			return b.Run()
+			return b.RunContext(makeContextForSignals())
 		},
 	}
-	buildCommand.Flags().StringVarP(&buildFlags.AppDir, "path", "p", "current working directory", "path to app dir")
-	buildCommand.Flags().StringVar(&buildFlags.Builder, "builder", "packs/samples", "builder")
-	buildCommand.Flags().StringVar(&buildFlags.RunImage, "run-image", "", "run image")
+	buildCommand.Flags().StringVarP(&buildFlags.AppDir, "path", "p", "current working directory", "path to app dir, app archive (.tgz, .zip) or git repository (url.git#ref:subdir)")
+	buildCommand.Flags().StringVar(&buildFlags.Builder, "builder", "", `builder (default from project.toml, or "`+pack.DefaultBuilder+`")`)
+	buildCommand.Flags().StringVar(&buildFlags.RunImage, "run-image", "", "run image (default from project.toml, or selected from the builder's stack)")
+	buildCommand.Flags().StringArrayVarP(&buildFlags.Tags, "tag", "t", []string{}, "additional image name to tag the app image with, may be repeated")
 	buildCommand.Flags().BoolVar(&buildFlags.Publish, "publish", false, "publish to registry")
-	buildCommand.Flags().BoolVar(&buildFlags.NoPull, "no-pull", false, "don't pull images before use")
-	buildCommand.Flags().StringArrayVar(&buildFlags.Buildpacks, "buildpack", []string{}, "buildpack ID to skip detection")
+	buildCommand.Flags().StringVar(&buildFlags.Export, "export", "", "write the image to oci:<dir> (OCI image layout) or docker-archive:<file> (for docker load) instead of the daemon or registry")
+	addPullPolicyFlags(buildCommand, &buildFlags.PullPolicy)
+	buildCommand.Flags().StringArrayVar(&buildFlags.Buildpacks, "buildpack", []string{}, "buildpack ID[@VERSION] or path to a local buildpack dir or .tgz to skip detection, may be repeated")
+	buildCommand.Flags().StringArrayVarP(&buildFlags.Env, "env", "e", []string{}, "build-time environment variable (KEY=VALUE), may be repeated")
+	buildCommand.Flags().StringVar(&buildFlags.EnvFile, "env-file", "", "file with build-time environment variables, one KEY=VALUE per line")
+	buildCommand.Flags().StringVar(&output, "output", "text", `output format, "text" or "json" (one event per line)`)
+	buildCommand.Flags().BoolVar(&buildFlags.ClearCache, "clear-cache", false, "clear the app's build cache before building")
+	buildCommand.Flags().StringVar(&buildFlags.CacheImage, "cache-image", "", "registry image to restore the build cache from and save it to, for runners without a persistent cache volume")
+	buildCommand.Flags().StringVar(&buildFlags.DefaultProcess, "default-process", "", "process type from launch.toml the image starts by default (e.g. web, worker)")
+	buildCommand.Flags().StringArrayVar(&buildFlags.Volumes, "volume", []string{}, "host:container[:ro] volume to mount into the detect and build containers, may be repeated")
+	buildCommand.Flags().StringVar(&buildFlags.Network, "network", "", "docker network to connect the lifecycle containers to")
+	buildCommand.Flags().BoolVar(&buildFlags.Reproducible, "reproducible", false, "normalize file and image dates (to SOURCE_DATE_EPOCH if set) so identical inputs give identical images")
+	buildCommand.Flags().StringVar(&buildFlags.Report, "report", "", "write a build report to this file (TOML, or JSON if it ends in .json)")
+	buildCommand.Flags().StringArrayVar(&buildFlags.Exclude, "exclude", []string{}, "gitignore-style pattern of app files to leave out, in addition to .packignore")
+	addInsecureRegistryFlag(buildCommand, &insecureRegistries)
 	return buildCommand
 }
 
@@ -75,7 +128,7 @@ func runCommand() *cobra.Command {
 		Use:  "run",
 		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			bf, err := pack.DefaultBuildFactory()
+			bf, err := pack.DefaultBuildFactory(newLogger())
 			if err != nil {
 				return err
 			}
@@ -91,11 +144,55 @@ func runCommand() *cobra.Command {
 	runCommand.Flags().StringVar(&runFlags.Builder, "builder", "packs/samples", "builder")
 	runCommand.Flags().StringVar(&runFlags.RunImage, "run-image", "packs/run", "run image")
 	runCommand.Flags().StringVar(&runFlags.Port, "port", "", "comma separated ports to publish, defaults to ports exposed by the container")
+	runCommand.Flags().StringVar(&runFlags.Process, "process", "", "process type from launch.toml to run instead of the image default")
+	addPullPolicyFlags(runCommand, &runFlags.PullPolicy)
 	return runCommand
 }
 
+func detectCommand() *cobra.Command {
+	var flags pack.DetectFlags
+	cmd := &cobra.Command{
+		Use:  "detect",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			bf, err := pack.DefaultBuildFactory(newLogger())
+			if err != nil {
+				return err
+			}
+			b, err := bf.DetectConfigFromFlags(&flags)
+			if err != nil {
+				return err
+			}
+			result, err := b.DetectOnly(makeContextForSignals())
+			if result != nil {
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "RESULT\tBUILDPACK")
+				for _, bp := range result.Buildpacks {
+					fmt.Fprintf(w, "%s\t%s\n", bp.Result, bp.Name)
+				}
+				w.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println("Detected group:")
+			for _, bp := range result.Group.Buildpacks {
+				fmt.Printf("  %s@%s\n", bp.ID, bp.Version)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&flags.AppDir, "path", "p", "current working directory", "path to app dir, app archive (.tgz, .zip) or git repository (url.git#ref:subdir)")
+	cmd.Flags().StringVar(&flags.Builder, "builder", "", `builder (default from project.toml, or "`+pack.DefaultBuilder+`")`)
+	cmd.Flags().StringArrayVar(&flags.Buildpacks, "buildpack", []string{}, "buildpack ID[@VERSION] or path to a local buildpack dir or .tgz to detect with instead of the builder's groups, may be repeated")
+	addPullPolicyFlags(cmd, &flags.PullPolicy)
+	return cmd
+}
+
 func rebaseCommand() *cobra.Command {
 	var flags pack.RebaseFlags
+	var insecureRegistries []string
 	cmd := &cobra.Command{
 		Use:  "rebase <image-name>",
 		Args: cobra.ExactArgs(1),
@@ -103,7 +200,8 @@ func rebaseCommand() *cobra.Command {
 			cmd.SilenceUsage = true
 			flags.RepoName = args[0]
 
-			docker, err := docker.New()
+			logger := newLogger()
+			docker, err := newDocker(logger)
 			if err != nil {
 				return err
 			}
@@ -111,8 +209,9 @@ func rebaseCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			useInsecureRegistries(cfg, insecureRegistries)
 			factory := pack.RebaseFactory{
-				Log:    log.New(os.Stdout, "", log.LstdFlags),
+				Log:    logger,
 				Docker: docker,
 				Config: cfg,
 				Images: &image.Client{},
@@ -125,12 +224,14 @@ func rebaseCommand() *cobra.Command {
 		},
 	}
 	cmd.Flags().BoolVar(&flags.Publish, "publish", false, "publish to registry")
-	cmd.Flags().BoolVar(&flags.NoPull, "no-pull", false, "don't pull images before use")
+	addPullPolicyFlags(cmd, &flags.PullPolicy)
+	addInsecureRegistryFlag(cmd, &insecureRegistries)
 	return cmd
 }
 
 func createBuilderCommand() *cobra.Command {
 	flags := pack.CreateBuilderFlags{}
+	var insecureRegistries []string
 	createBuilderCommand := &cobra.Command{
 		Use:  "create-builder <image-name> -b <path-to-builder-toml>",
 		Args: cobra.MinimumNArgs(1),
@@ -138,7 +239,8 @@ func createBuilderCommand() *cobra.Command {
 			cmd.SilenceUsage = true
 			flags.RepoName = args[0]
 
-			docker, err := docker.New()
+			logger := newLogger()
+			docker, err := newDocker(logger)
 			if err != nil {
 				return err
 			}
@@ -146,9 +248,10 @@ func createBuilderCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			useInsecureRegistries(cfg, insecureRegistries)
 			builderFactory := pack.BuilderFactory{
 				FS:     &fs.FS{},
-				Log:    log.New(os.Stdout, "", log.LstdFlags),
+				Log:    logger,
 				Docker: docker,
 				Config: cfg,
 				Images: &image.Client{},
@@ -160,13 +263,194 @@ func createBuilderCommand() *cobra.Command {
 			return builderFactory.Create(builderConfig)
 		},
 	}
-	createBuilderCommand.Flags().BoolVar(&flags.NoPull, "no-pull", false, "don't pull stack image before use")
+	addPullPolicyFlags(createBuilderCommand, &flags.PullPolicy)
 	createBuilderCommand.Flags().StringVarP(&flags.BuilderTomlPath, "builder-config", "b", "", "path to builder.toml file")
 	createBuilderCommand.Flags().StringVarP(&flags.StackID, "stack", "s", "", "stack ID")
 	createBuilderCommand.Flags().BoolVar(&flags.Publish, "publish", false, "publish to registry")
+	addInsecureRegistryFlag(createBuilderCommand, &insecureRegistries)
 	return createBuilderCommand
 }
 
+func cacheCommand() *cobra.Command {
+	cacheCommand := &cobra.Command{
+		Use:  "cache",
+		Args: cobra.NoArgs,
+	}
+
+	newFactory := func() (*pack.CacheFactory, error) {
+		logger := newLogger()
+		docker, err := newDocker(logger)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := config.New(filepath.Join(os.Getenv("HOME"), ".pack"))
+		if err != nil {
+			return nil, err
+		}
+		return &pack.CacheFactory{
+			Log:    logger,
+			Docker: docker,
+			Config: cfg,
+		}, nil
+	}
+
+	cacheCommand.AddCommand(&cobra.Command{
+		Use:  "list",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			factory, err := newFactory()
+			if err != nil {
+				return err
+			}
+			caches, err := factory.List()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VOLUME\tAPP PATH\tSIZE\tLAST USED")
+			for _, c := range caches {
+				appPath, size, lastUsed := "unknown", "unknown", "unknown"
+				if c.AppPath != "" {
+					appPath = c.AppPath
+				}
+				if c.Size >= 0 {
+					size = humanSize(c.Size)
+				}
+				if !c.LastUsed.IsZero() {
+					lastUsed = c.LastUsed.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, appPath, size, lastUsed)
+			}
+			return w.Flush()
+		},
+	})
+
+	var clearFlags pack.ClearCacheFlags
+	clearCommand := &cobra.Command{
+		Use:  "clear",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			factory, err := newFactory()
+			if err != nil {
+				return err
+			}
+			removed, err := factory.Clear(clearFlags)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d cache volume(s) removed\n", len(removed))
+			return nil
+		},
+	}
+	clearCommand.Flags().StringVarP(&clearFlags.AppDir, "path", "p", "", "only clear the cache for this app dir")
+	clearCommand.Flags().DurationVar(&clearFlags.OlderThan, "older-than", 0, "only clear caches not used within this duration (e.g. 720h)")
+	cacheCommand.AddCommand(clearCommand)
+
+	return cacheCommand
+}
+
+func pruneCommand() *cobra.Command {
+	var flags pack.PruneFlags
+	cmd := &cobra.Command{
+		Use:  "prune",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger := newLogger()
+			docker, err := newDocker(logger)
+			if err != nil {
+				return err
+			}
+			cfg, err := config.New(filepath.Join(os.Getenv("HOME"), ".pack"))
+			if err != nil {
+				return err
+			}
+			factory := pack.PruneFactory{
+				Log:    logger,
+				Docker: docker,
+				Config: cfg,
+			}
+			result, err := factory.Prune(flags)
+			if err != nil {
+				return err
+			}
+			verb := "Reclaimed"
+			if flags.DryRun {
+				verb = "Would reclaim"
+			}
+			fmt.Printf("%s %s from %d container(s), %d volume(s) and %d image(s)\n", verb, humanSize(result.Reclaimed), len(result.Containers), len(result.Volumes), len(result.Images))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "only list what would be removed")
+	cmd.Flags().BoolVar(&flags.AppVolumes, "app-volumes", false, "also remove the app volumes pack run keeps for quick rebuilds")
+	return cmd
+}
+
+func newLogger() logging.Logger {
+	return logging.New(os.Stdout, os.Stderr, logOptions)
+}
+
+// newDocker returns a docker client that shows pull progress through logger,
+// so --quiet and --timestamps apply to it too.
+func newDocker(logger logging.Logger) (*docker.Client, error) {
+	cli, err := docker.New()
+	if err != nil {
+		return nil, err
+	}
+	cli.PullOut = logger.Writer()
+	return cli, nil
+}
+
+// addInsecureRegistryFlag adds --insecure-registry. Pulls through the docker
+// daemon are still governed by the daemon's own insecure-registries setting.
+func addInsecureRegistryFlag(cmd *cobra.Command, registries *[]string) {
+	cmd.Flags().StringArrayVar(registries, "insecure-registry", []string{}, "registry host[:port] to reach over plain HTTP or without TLS verification, in addition to insecure-registries in ~/.pack/config.toml, may be repeated (host and port must match exactly, as for the docker daemon)")
+}
+
+// useInsecureRegistries sets up registry access for the insecure-registries
+// in the config and those given with --insecure-registry.
+func useInsecureRegistries(cfg *config.Config, flagged []string) {
+	registries := append([]string{}, cfg.InsecureRegistries...)
+	image.UseInsecureRegistries(append(registries, flagged...))
+}
+
+// addPullPolicyFlags adds --pull-policy, and --no-pull as an alias for
+// --pull-policy never, and sets policy from them before the command runs.
+func addPullPolicyFlags(cmd *cobra.Command, policy *pack.PullPolicy) {
+	var value string
+	var noPull bool
+	cmd.Flags().StringVar(&value, "pull-policy", string(pack.PullAlways), `when to pull images before use, "always", "if-not-present" or "never"`)
+	cmd.Flags().BoolVar(&noPull, "no-pull", false, "don't pull images before use (same as --pull-policy never)")
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if noPull {
+			if cmd.Flags().Changed("pull-policy") && value != string(pack.PullNever) {
+				return fmt.Errorf("--no-pull cannot be combined with --pull-policy %s", value)
+			}
+			*policy = pack.PullNever
+			return nil
+		}
+		var err error
+		*policy, err = pack.ParsePullPolicy(value)
+		return err
+	}
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func addStackCommand() *cobra.Command {
 	flags := struct {
 		BuildImages []string
@@ -276,6 +560,21 @@ func versionCommand() *cobra.Command {
 	}
 }
 
+// makeContextForSignals returns a context that is cancelled on the first
+// interrupt, giving the build a chance to clean up. A second interrupt exits immediately.
+func makeContextForSignals() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCh := makeStopChannelForSignals()
+	go func() {
+		<-stopCh
+		fmt.Fprintln(os.Stderr, "Interrupted, cleaning up (interrupt again to force exit)")
+		cancel()
+		<-stopCh
+		os.Exit(130)
+	}()
+	return ctx
+}
+
 func makeStopChannelForSignals() <-chan struct{} {
 	sigsCh := make(chan os.Signal, 1)
 	stopCh := make(chan struct{}, 1)
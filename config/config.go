@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -13,7 +14,10 @@ import (
 type Config struct {
 	Stacks         []Stack `toml:"stacks"`
 	DefaultStackID string  `toml:"default-stack-id"`
-	configPath     string
+	Caches         []Cache `toml:"caches"`
+	// InsecureRegistries are reached without TLS verification, or over plain HTTP
+	InsecureRegistries []string `toml:"insecure-registries,omitempty"`
+	configPath         string
 }
 
 type Stack struct {
@@ -22,6 +26,13 @@ type Stack struct {
 	RunImages   []string `toml:"run-images"`
 }
 
+// Cache records which app a build cache volume belongs to and when a build last used it
+type Cache struct {
+	Volume   string    `toml:"volume"`
+	AppPath  string    `toml:"app-path"`
+	LastUsed time.Time `toml:"last-used"`
+}
+
 func New(path string) (*Config, error) {
 	configPath := filepath.Join(path, "config.toml")
 	config, err := previousConfig(path)
@@ -46,6 +57,11 @@ func New(path string) (*Config, error) {
 	return config, nil
 }
 
+// Dir is the directory of the config file, pack keeps its other state there too.
+func (c *Config) Dir() string {
+	return filepath.Dir(c.configPath)
+}
+
 func (c *Config) save() error {
 	if err := os.MkdirAll(filepath.Dir(c.configPath), 0777); err != nil {
 		return err
@@ -136,6 +152,37 @@ func (c *Config) SetDefaultStack(stackID string) error {
 	return fmt.Errorf(`"%s" does not exist. Please pass in a valid stack ID.`, stackID)
 }
 
+func (c *Config) GetCache(volume string) *Cache {
+	for _, cache := range c.Caches {
+		if cache.Volume == volume {
+			return &cache
+		}
+	}
+	return nil
+}
+
+func (c *Config) TouchCache(volume, appPath string, lastUsed time.Time) error {
+	for i, cache := range c.Caches {
+		if cache.Volume == volume {
+			c.Caches[i].AppPath = appPath
+			c.Caches[i].LastUsed = lastUsed
+			return c.save()
+		}
+	}
+	c.Caches = append(c.Caches, Cache{Volume: volume, AppPath: appPath, LastUsed: lastUsed})
+	return c.save()
+}
+
+func (c *Config) DeleteCache(volume string) error {
+	for i, cache := range c.Caches {
+		if cache.Volume == volume {
+			c.Caches = append(c.Caches[:i], c.Caches[i+1:]...)
+			return c.save()
+		}
+	}
+	return nil
+}
+
 func ImageByRegistry(registry string, images []string) (string, error) {
 	if len(images) == 0 {
 		return "", errors.New("empty images")
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/buildpack/pack/config"
 	"github.com/google/go-cmp/cmp"
@@ -119,6 +120,24 @@ default-stack-id = "some.user.provided.stack"
 			})
 		})
 
+		when("config on disk has insecure registries", func() {
+			it.Before(func() {
+				assertNil(t, ioutil.WriteFile(filepath.Join(tmpDir, "config.toml"), []byte(`
+insecure-registries = ["localhost:5000", "registry.internal"]
+`), 0666))
+			})
+
+			it("reads and preserves them", func() {
+				subject, err := config.New(tmpDir)
+				assertNil(t, err)
+				assertEq(t, subject.InsecureRegistries, []string{"localhost:5000", "registry.internal"})
+
+				b, err := ioutil.ReadFile(filepath.Join(tmpDir, "config.toml"))
+				assertNil(t, err)
+				assertContains(t, string(b), `insecure-registries = ["localhost:5000", "registry.internal"]`)
+			})
+		})
+
 		when("config.toml already has the built-in stack", func() {
 			it.Before(func() {
 				w, err := os.Create(filepath.Join(tmpDir, "config.toml"))
@@ -480,6 +499,38 @@ default-stack-id = "stack-1"
 			})
 		})
 	})
+
+	when("Config#TouchCache", func() {
+		var subject *config.Config
+		it.Before(func() {
+			var err error
+			subject, err = config.New(tmpDir)
+			assertNil(t, err)
+		})
+
+		it("records the cache and updates it on later use", func() {
+			first := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+			second := time.Date(2018, 10, 2, 0, 0, 0, 0, time.UTC)
+			assertNil(t, subject.TouchCache("pack-cache-123", "/some/app", first))
+			assertNil(t, subject.TouchCache("pack-cache-123", "/some/app", second))
+
+			assertEq(t, len(subject.Caches), 1)
+			reloaded, err := config.New(tmpDir)
+			assertNil(t, err)
+			cache := reloaded.GetCache("pack-cache-123")
+			assertNotNil(t, cache)
+			assertEq(t, cache.AppPath, "/some/app")
+			assertEq(t, cache.LastUsed.Equal(second), true)
+		})
+
+		it("forgets deleted caches", func() {
+			assertNil(t, subject.TouchCache("pack-cache-123", "/some/app", time.Now()))
+			assertNil(t, subject.DeleteCache("pack-cache-123"))
+			if subject.GetCache("pack-cache-123") != nil {
+				t.Fatal("expected cache to be deleted")
+			}
+		})
+	})
 }
 
 func assertContains(t *testing.T, actual, expected string) {
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 
@@ -14,9 +13,12 @@ import (
 	"github.com/buildpack/lifecycle"
 	"github.com/buildpack/lifecycle/img"
 	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
 	"net/http"
@@ -50,13 +52,18 @@ type Buildpack struct {
 type Docker interface {
 	PullImage(ref string) error
 	RunContainer(ctx context.Context, id string, stdout io.Writer, stderr io.Writer) error
+	VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (types.Volume, error)
 	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+	VolumeList(ctx context.Context, filter filters.Args) (volume.VolumeListOKBody, error)
+	VolumeInspect(ctx context.Context, volumeID string) (types.Volume, error)
+	DiskUsage(ctx context.Context) (types.DiskUsage, error)
 	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error)
 	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
 	CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options types.CopyToContainerOptions) error
 	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
-	ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error)
 	ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error)
+	ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)
+	ImageTag(ctx context.Context, image, ref string) error
 }
 
 //go:generate mockgen -package mocks -destination mocks/images.go github.com/buildpack/pack Images
@@ -71,7 +78,7 @@ type Task interface {
 }
 
 type BuilderFactory struct {
-	Log    *log.Logger
+	Log    logging.Logger
 	Docker Docker
 	FS     FS
 	Config *config.Config
@@ -82,6 +89,7 @@ type BuilderFactory struct {
 type FS interface {
 	CreateTGZFile(tarFile, srcDir, tarDir string, uid, gid int) error
 	CreateTarReader(srcDir, tarDir string, uid, gid int) (io.Reader, chan error)
+	CreateFilesTarReader(srcDir, tarDir string, paths []string, uid, gid int) (io.Reader, chan error)
 	Untar(r io.Reader, dest string) error
 	CreateSingleFileTar(path, txt string) (io.Reader, error)
 }
@@ -91,7 +99,7 @@ type CreateBuilderFlags struct {
 	BuilderTomlPath string
 	StackID         string
 	Publish         bool
-	NoPull          bool
+	PullPolicy      PullPolicy
 }
 
 func (f *BuilderFactory) BuilderConfigFromFlags(flags CreateBuilderFlags) (BuilderConfig, error) {
@@ -99,11 +107,14 @@ func (f *BuilderFactory) BuilderConfigFromFlags(flags CreateBuilderFlags) (Build
 	if err != nil {
 		return BuilderConfig{}, err
 	}
-	if !flags.NoPull && !flags.Publish {
-		f.Log.Println("Pulling builder base image ", baseImage)
-		err := f.Docker.PullImage(baseImage)
-		if err != nil {
-			return BuilderConfig{}, fmt.Errorf(`failed to pull stack build image "%s": %s`, baseImage, err)
+	if !flags.Publish {
+		if pull, err := shouldPull(f.Docker, flags.PullPolicy, baseImage); err != nil {
+			return BuilderConfig{}, err
+		} else if pull {
+			f.Log.Info("Pulling builder base image %s", baseImage)
+			if err := f.Docker.PullImage(baseImage); err != nil {
+				return BuilderConfig{}, fmt.Errorf(`failed to pull stack build image "%s": %s`, baseImage, err)
+			}
 		}
 	}
 
@@ -251,9 +262,9 @@ func (f *BuilderFactory) Create(config BuilderConfig) error {
 		return err
 	}
 
-	f.Log.Println("Successfully created builder image:", config.RepoName)
-	f.Log.Println("")
-	f.Log.Println(`Tip: Run "pack build <image name> --builder <builder image> --path <app source code>" to use this builder`)
+	f.Log.Result("Successfully created builder image: %s", config.RepoName)
+	f.Log.Info("")
+	f.Log.Info(`Tip: Run "pack build <image name> --builder <builder image> --path <app source code>" to use this builder`)
 
 	return nil
 }
@@ -343,7 +354,7 @@ func (f *BuilderFactory) latestLayer(buildpacks []Buildpack, dest, builderDir st
 			}
 			err = os.Symlink(filepath.Join("/", "buildpacks", bp.ID, data.BP.Version), filepath.Join(tmpDir, bp.ID, "latest"))
 			if err != nil {
-				fmt.Println("E")
+				f.Log.Warn("failed to link latest version of buildpack '%s': %s", bp.ID, err)
 			}
 		}
 	}
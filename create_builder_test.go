@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
 	"os/exec"
@@ -17,6 +16,7 @@ import (
 	"github.com/buildpack/pack"
 	"github.com/buildpack/pack/config"
 	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
@@ -49,7 +49,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 			factory = pack.BuilderFactory{
 				FS:     &fs.FS{},
 				Docker: mockDocker,
-				Log:    log.New(&buf, "", log.LstdFlags),
+				Log:    logging.New(&buf, &buf, logging.Options{}),
 				Config: &config.Config{
 					DefaultStackID: "some.default.stack",
 					Stacks: []config.Stack{
@@ -132,7 +132,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 				config, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 					RepoName:        "some/image",
 					BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				})
 				if err != nil {
 					t.Fatalf("error creating builder config: %s", err)
@@ -150,7 +150,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 					RepoName:        "some/image",
 					BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				})
 				if err == nil {
 					t.Fatalf("Expected error when base image is missing from daemon")
@@ -181,7 +181,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 				_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 					RepoName:        "some/image",
 					BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				})
 				assertError(t, err, `Invalid stack: stack "some.bad.stack" requires at least one build image`)
 			})
@@ -214,7 +214,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 					_, err := factory.BuilderConfigFromFlags(pack.CreateBuilderFlags{
 						RepoName:        "some/image",
 						BuilderTomlPath: filepath.Join("testdata", "builder.toml"),
-						NoPull:          true,
+						PullPolicy:      pack.PullNever,
 						StackID:         "some.missing.stack",
 					})
 					assertError(t, err, `Missing stack: stack with id "some.missing.stack" not found in pack config.toml`)
@@ -283,7 +283,7 @@ func testCreateBuilder(t *testing.T, when spec.G, it spec.S) {
 					BuilderTomlPath: "testdata/used-to-test-various-uri-schemes/builder-with-schemeless-uris.toml",
 					StackID:         "some.default.stack",
 					Publish:         false,
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				}
 
 				builderConfig, err := factory.BuilderConfigFromFlags(flags)
@@ -329,7 +329,7 @@ buildpacks = [
 					BuilderTomlPath: f.Name(),
 					StackID:         "some.default.stack",
 					Publish:         false,
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				}
 
 				builderConfig, err := factory.BuilderConfigFromFlags(flags)
@@ -377,7 +377,7 @@ buildpacks = [
 					BuilderTomlPath: f.Name(),
 					StackID:         "some.default.stack",
 					Publish:         false,
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				}
 
 				builderConfig, err := factory.BuilderConfigFromFlags(flags)
@@ -444,7 +444,7 @@ buildpacks = [
 					BuilderTomlPath: f.Name(),
 					StackID:         "some.default.stack",
 					Publish:         false,
-					NoPull:          true,
+					PullPolicy:      pack.PullNever,
 				}
 
 				builderConfig, err := factory.BuilderConfigFromFlags(flags)
@@ -0,0 +1,101 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/pack/docker"
+	"github.com/buildpack/packs"
+	"github.com/pkg/errors"
+)
+
+// ErrNoGroupDetected is returned by DetectOnly when no buildpack group passes.
+var ErrNoGroupDetected = errors.New("no buildpack group passed detection")
+
+type DetectFlags struct {
+	AppDir     string
+	Builder    string
+	Buildpacks []string
+	PullPolicy PullPolicy
+}
+
+type DetectResult struct {
+	// Group is nil when no group passed
+	Group *lifecycle.BuildpackGroup
+	// Buildpacks are in the order the detector tried them, by name since
+	// that is all the detector prints
+	Buildpacks []DetectedBuildpack
+}
+
+type DetectedBuildpack struct {
+	Name   string
+	Result string // pass, fail, skip or error
+}
+
+// DetectConfigFromFlags prepares a build that only runs detection. The run
+// image is neither selected nor pulled.
+func (bf *BuildFactory) DetectConfigFromFlags(f *DetectFlags) (*BuildConfig, error) {
+	return bf.BuildConfigFromFlags(&BuildFlags{
+		AppDir:     f.AppDir,
+		Builder:    f.Builder,
+		Buildpacks: f.Buildpacks,
+		PullPolicy: f.PullPolicy,
+		detectOnly: true,
+	})
+}
+
+// DetectOnly runs the detect phase on its own and reports the result of every
+// buildpack. Nothing is built, so the cache volume is never created.
+func (b *BuildConfig) DetectOnly(ctx context.Context) (*DetectResult, error) {
+	if b.cleanup != nil {
+		defer b.cleanup()
+	}
+	defer b.Cli.VolumeRemove(context.Background(), b.WorkspaceVolume, true)
+
+	var out bytes.Buffer
+	stdout, stderr := b.Stdout, b.Stderr
+	b.Stdout, b.Stderr = io.MultiWriter(stdout, &out), io.MultiWriter(stderr, &out)
+	defer func() { b.Stdout, b.Stderr = stdout, stderr }()
+
+	group, err := b.Detect(ctx)
+	result := &DetectResult{Group: group, Buildpacks: parseDetectOutput(out.String())}
+	if exitErr, ok := errors.Cause(err).(*docker.ExitError); ok && exitErr.StatusCode == int64(packs.CodeFailedDetect) {
+		return result, ErrNoGroupDetected
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+var (
+	detectLogPrefix = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+	detectResult    = regexp.MustCompile(`^(.+): (pass|fail|skip|error)\b`)
+)
+
+// parseDetectOutput picks the result lines out of the detector output. The
+// detector prints one line per group it tries, e.g.
+// "Some Buildpack: pass | Other Buildpack: fail".
+func parseDetectOutput(output string) []DetectedBuildpack {
+	var results []DetectedBuildpack
+	for _, line := range strings.Split(output, "\n") {
+		line = detectLogPrefix.ReplaceAllString(strings.TrimSpace(line), "")
+		if line == "" {
+			continue
+		}
+		var group []DetectedBuildpack
+		for _, part := range strings.Split(line, " | ") {
+			m := detectResult.FindStringSubmatch(strings.TrimSpace(part))
+			if m == nil {
+				group = nil
+				break
+			}
+			group = append(group, DetectedBuildpack{Name: m[1], Result: m[2]})
+		}
+		results = append(results, group...)
+	}
+	return results
+}
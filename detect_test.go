@@ -0,0 +1,150 @@
+package pack_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/docker"
+	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/image"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	dockertypes "github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestDetect(t *testing.T) {
+	rand.Seed(time.Now().UTC().UnixNano())
+	assertNil(t, exec.Command("docker", "pull", "packs/samples").Run())
+	spec.Run(t, "detect", testDetect, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testDetect(t *testing.T, when spec.G, it spec.S) {
+	var buf bytes.Buffer
+
+	when("#DetectConfigFromFlags", func() {
+		var (
+			mockController *gomock.Controller
+			mockDocker     *mocks.MockDocker
+			factory        *pack.BuildFactory
+		)
+
+		it.Before(func() {
+			mockController = gomock.NewController(t)
+			mockDocker = mocks.NewMockDocker(mockController)
+			factory = &pack.BuildFactory{
+				Cli:    mockDocker,
+				Stdout: &buf,
+				Stderr: &buf,
+				Log:    logging.New(&buf, &buf, logging.Options{}),
+				FS:     &fs.FS{},
+				Config: &config.Config{},
+			}
+		})
+
+		it.After(func() {
+			mockController.Finish()
+		})
+
+		it("pulls the builder but not a run image", func() {
+			mockDocker.EXPECT().PullImage("some/builder")
+			mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "some/builder").Return(dockertypes.ImageInspect{
+				Config: &dockercontainer.Config{
+					Labels: map[string]string{"io.buildpacks.stack.id": "some.stack.id"},
+				},
+			}, nil, nil)
+
+			b, err := factory.DetectConfigFromFlags(&pack.DetectFlags{
+				AppDir:     "acceptance/testdata/node_app",
+				Builder:    "some/builder",
+				Buildpacks: []string{"some.bp@1.0"},
+			})
+			assertNil(t, err)
+			assertEq(t, b.Builder, "some/builder")
+			assertEq(t, b.Buildpacks, []string{"some.bp@1.0"})
+			assertEq(t, b.RunImage, "")
+		})
+	})
+
+	when("#DetectOnly", func() {
+		var subject *pack.BuildConfig
+
+		it.Before(func() {
+			var err error
+			subject = &pack.BuildConfig{
+				AppDir:          "acceptance/testdata/node_app",
+				Builder:         "packs/samples",
+				WorkspaceVolume: fmt.Sprintf("pack-workspace-%x", uuid.New().String()),
+				CacheVolume:     fmt.Sprintf("pack-cache-%x", uuid.New().String()),
+				Stdout:          &buf,
+				Stderr:          &buf,
+				Log:             logging.New(&buf, &buf, logging.Options{}),
+				FS:              &fs.FS{},
+				Images:          &image.Client{},
+			}
+			subject.Cli, err = docker.New()
+			assertNil(t, err)
+		})
+
+		when("app is detected", func() {
+			it("returns the group and the result of each buildpack", func() {
+				result, err := subject.DetectOnly(context.Background())
+				assertNil(t, err)
+				assertEq(t, result.Group.Buildpacks[0].ID, "io.buildpacks.samples.nodejs")
+				var passed []string
+				for _, bp := range result.Buildpacks {
+					if bp.Result == "pass" {
+						passed = append(passed, bp.Name)
+					}
+				}
+				assertContains(t, strings.Join(passed, ","), "Node.js")
+			})
+
+			it("doesn't create the cache volume", func() {
+				_, err := subject.DetectOnly(context.Background())
+				assertNil(t, err)
+				assertNotNil(t, exec.Command("docker", "volume", "inspect", subject.CacheVolume).Run())
+			})
+		})
+
+		when("app is not detectable", func() {
+			var badappDir string
+			it.Before(func() {
+				var err error
+				badappDir, err = ioutil.TempDir("/tmp", "pack.detect.badapp.")
+				assertNil(t, err)
+				assertNil(t, ioutil.WriteFile(filepath.Join(badappDir, "file.txt"), []byte("content"), 0644))
+				subject.AppDir = badappDir
+			})
+			it.After(func() { os.RemoveAll(badappDir) })
+
+			it("returns ErrNoGroupDetected with the failed buildpacks", func() {
+				result, err := subject.DetectOnly(context.Background())
+				assertSameInstance(t, err, pack.ErrNoGroupDetected)
+				assertNotNil(t, result)
+				var failed []string
+				for _, bp := range result.Buildpacks {
+					if bp.Result == "fail" {
+						failed = append(failed, bp.Name)
+					}
+				}
+				assertContains(t, strings.Join(failed, ","), "Node.js")
+			})
+		})
+	})
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 
+	"github.com/buildpack/pack/auth"
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	dockercli "github.com/docker/docker/client"
@@ -15,6 +17,8 @@ import (
 
 type Client struct {
 	*dockercli.Client
+	// PullOut receives the progress of image pulls, nothing is shown if nil
+	PullOut io.Writer
 }
 
 func New() (*Client, error) {
@@ -22,7 +26,16 @@ func New() (*Client, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "new docker client")
 	}
-	return &Client{cli}, nil
+	return &Client{Client: cli, PullOut: os.Stdout}, nil
+}
+
+// ExitError is returned by RunContainer when the container exits non-zero.
+type ExitError struct {
+	StatusCode int64
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("failed with status code: %d", e.StatusCode)
 }
 
 func (d *Client) RunContainer(ctx context.Context, id string, stdout io.Writer, stderr io.Writer) error {
@@ -60,22 +73,27 @@ func (d *Client) RunContainer(ctx context.Context, id string, stdout io.Writer,
 	select {
 	case body := <-bodyChan:
 		if body.StatusCode != 0 {
-			return fmt.Errorf("failed with status code: %d", body.StatusCode)
+			return &ExitError{StatusCode: body.StatusCode}
 		}
 	case err := <-errChan:
-		fmt.Printf("ERR: %#v\n", err)
 		return err
 	}
 	return nil
 }
 
 func (d *Client) PullImage(ref string) error {
-	rc, err := d.ImagePull(context.Background(), ref, dockertypes.ImagePullOptions{})
+	registryAuth, err := auth.RegistryAuth(ref)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "credentials for '%s'", ref)
 	}
-	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+	rc, err := d.ImagePull(context.Background(), ref, dockertypes.ImagePullOptions{RegistryAuth: registryAuth})
+	if err != nil {
 		return err
 	}
-	return rc.Close()
+	defer rc.Close()
+	out := d.PullOut
+	if out == nil {
+		out = ioutil.Discard
+	}
+	return DisplayPullProgress(rc, out, isTerminal(out))
 }
@@ -0,0 +1,167 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// summaryInterval is how often pull progress is summarized when the output
+// is not a terminal.
+const summaryInterval = 5 * time.Second
+
+// pullMessage is one line of the JSON stream the daemon sends during a pull.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Progress       string `json:"progress"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+type pullLayer struct {
+	line    int
+	current int64
+	total   int64
+	done    bool
+}
+
+type pullProgress struct {
+	out      io.Writer
+	terminal bool
+	layers   map[string]*pullLayer
+	order    []string
+	lines    int
+	last     time.Time
+}
+
+// DisplayPullProgress reads the JSON stream of an image pull and writes its
+// progress to out. On a terminal every layer gets a line that is updated in
+// place, otherwise a summary of all layers is written every few seconds. An
+// error reported in the stream is returned, the daemon still answers such
+// pulls with a successful status.
+func DisplayPullProgress(in io.Reader, out io.Writer, terminal bool) error {
+	p := &pullProgress{
+		out:      out,
+		terminal: terminal,
+		layers:   map[string]*pullLayer{},
+		last:     time.Now(),
+	}
+	dec := json.NewDecoder(in)
+	for {
+		var m pullMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "read pull progress")
+		}
+		if m.ErrorDetail != nil && m.ErrorDetail.Message != "" {
+			return errors.New(m.ErrorDetail.Message)
+		} else if m.Error != "" {
+			return errors.New(m.Error)
+		}
+		p.update(m)
+	}
+	if !p.terminal && len(p.order) > 0 {
+		p.summarize()
+	}
+	return nil
+}
+
+func (p *pullProgress) update(m pullMessage) {
+	if m.ID == "" || strings.HasPrefix(m.Status, "Pulling from ") {
+		// about the image rather than one of its layers, the id is the tag
+		p.println(statusLine(m))
+		return
+	}
+
+	l, ok := p.layers[m.ID]
+	if !ok {
+		l = &pullLayer{line: -1}
+		p.layers[m.ID] = l
+		p.order = append(p.order, m.ID)
+	}
+	switch m.Status {
+	case "Downloading":
+		l.current, l.total = m.ProgressDetail.Current, m.ProgressDetail.Total
+	case "Download complete", "Verifying Checksum":
+		l.current = l.total
+	case "Pull complete", "Already exists":
+		l.current = l.total
+		l.done = true
+	}
+
+	if p.terminal {
+		p.redraw(l, statusLine(m))
+	} else if time.Since(p.last) >= summaryInterval {
+		p.summarize()
+	}
+}
+
+// redraw replaces the line of a layer, moving the cursor up to it and back
+func (p *pullProgress) redraw(l *pullLayer, line string) {
+	if l.line < 0 {
+		l.line = p.lines
+		p.println(line)
+		return
+	}
+	up := p.lines - l.line
+	fmt.Fprintf(p.out, "\x1b[%dA\r\x1b[2K%s\x1b[%dB\r", up, line, up)
+}
+
+func (p *pullProgress) summarize() {
+	var done int
+	var current, total int64
+	for _, id := range p.order {
+		l := p.layers[id]
+		if l.done {
+			done++
+		}
+		current += l.current
+		total += l.total
+	}
+	p.println(fmt.Sprintf("Pulled %d of %d layers, downloaded %s of %s", done, len(p.order), megabytes(current), megabytes(total)))
+	p.last = time.Now()
+}
+
+func (p *pullProgress) println(line string) {
+	fmt.Fprintln(p.out, line)
+	p.lines++
+}
+
+func statusLine(m pullMessage) string {
+	line := m.Status
+	if m.ID != "" {
+		line = m.ID + ": " + line
+	}
+	if m.Progress != "" {
+		line += " " + m.Progress
+	}
+	return line
+}
+
+func megabytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/1000/1000)
+}
+
+// isTerminal reports whether w is a character device, which is as close to a
+// terminal check as the standard library gets.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
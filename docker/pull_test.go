@@ -0,0 +1,72 @@
+package docker_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/buildpack/pack/docker"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestPull(t *testing.T) {
+	spec.Run(t, "pull", testPull, spec.Report(report.Terminal{}))
+}
+
+func testPull(t *testing.T, when spec.G, it spec.S) {
+	when("#DisplayPullProgress", func() {
+		const stream = `{"status":"Pulling from some/image","id":"latest"}
+{"status":"Already exists","progressDetail":{},"id":"aaa"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"bbb"}
+{"status":"Downloading","progressDetail":{"current":1000000,"total":3000000},"progress":"[=>   ]","id":"bbb"}
+{"status":"Download complete","progressDetail":{},"id":"bbb"}
+{"status":"Pull complete","progressDetail":{},"id":"bbb"}
+{"status":"Digest: sha256:abc"}
+{"status":"Status: Downloaded newer image for some/image:latest"}
+`
+		it("summarizes layers when the output is not a terminal", func() {
+			var out bytes.Buffer
+			err := docker.DisplayPullProgress(strings.NewReader(stream), &out, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := `latest: Pulling from some/image
+Digest: sha256:abc
+Status: Downloaded newer image for some/image:latest
+Pulled 2 of 2 layers, downloaded 3.0 MB of 3.0 MB
+`
+			if out.String() != expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", expected, out.String())
+			}
+		})
+
+		it("updates a line per layer on a terminal", func() {
+			var out bytes.Buffer
+			err := docker.DisplayPullProgress(strings.NewReader(stream), &out, true)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, line := range []string{
+				"aaa: Already exists\n",
+				"bbb: Pulling fs layer\n",
+				"\x1b[1A\r\x1b[2Kbbb: Downloading [=>   ]\x1b[1B\r",
+				"\x1b[1A\r\x1b[2Kbbb: Pull complete\x1b[1B\r",
+			} {
+				if !strings.Contains(out.String(), line) {
+					t.Fatalf("expected %q to contain %q", out.String(), line)
+				}
+			}
+		})
+
+		it("returns errors reported in the stream", func() {
+			stream := `{"status":"Pulling from some/image","id":"latest"}
+{"errorDetail":{"message":"manifest for some/image:latest not found"},"error":"manifest for some/image:latest not found"}
+`
+			err := docker.DisplayPullProgress(strings.NewReader(stream), &bytes.Buffer{}, false)
+			if err == nil || err.Error() != "manifest for some/image:latest not found" {
+				t.Fatalf("expected the stream error, got: %v", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/buildpack/pack/logging"
+)
+
+const (
+	EventPhaseStart = "phase-start"
+	EventPhaseEnd   = "phase-end"
+	EventPull       = "pull"
+	EventGroup      = "group"
+	EventLayer      = "layer"
+	EventImage      = "image"
+	EventError      = "error"
+	EventLog        = "log"
+)
+
+type Event struct {
+	Type       string           `json:"type"`
+	Time       time.Time        `json:"time"`
+	Phase      string           `json:"phase,omitempty"`
+	Duration   float64          `json:"duration,omitempty"` // seconds, on phase-end
+	Image      string           `json:"image,omitempty"`
+	Digest     string           `json:"digest,omitempty"`
+	Buildpacks []EventBuildpack `json:"buildpacks,omitempty"`
+	Buildpack  string           `json:"buildpack,omitempty"`
+	Layer      string           `json:"layer,omitempty"`
+	Reused     *bool            `json:"reused,omitempty"`
+	Stream     string           `json:"stream,omitempty"`
+	Message    string           `json:"message,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+type EventBuildpack struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+type Events interface {
+	Emit(e Event)
+}
+
+// TextEvents is the default, human readable output. It only logs the phase
+// banners; everything else already reaches the user through the log.
+type TextEvents struct {
+	Log logging.Logger
+}
+
+func (t *TextEvents) Emit(e Event) {
+	if e.Type == EventPhaseStart && e.Message != "" {
+		t.Log.Info("*** %s", e.Message)
+	}
+}
+
+// JSONEvents writes every event as a single line of JSON.
+type JSONEvents struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONEvents(out io.Writer) *JSONEvents {
+	return &JSONEvents{enc: json.NewEncoder(out)}
+}
+
+func (j *JSONEvents) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(e)
+}
+
+// Writer returns a writer that wraps each line written to it as a log event
+// on the given stream, so container and log output stay valid JSON lines.
+func (j *JSONEvents) Writer(stream string) io.Writer {
+	return &eventWriter{events: j, stream: stream}
+}
+
+type eventWriter struct {
+	events Events
+	stream string
+	mu     sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.events.Emit(Event{Type: EventLog, Stream: w.stream, Message: line[:len(line)-1]})
+	}
+	return len(p), nil
+}
+
+func layerEvent(buildpack, layer string, reused bool) Event {
+	return Event{Type: EventLayer, Buildpack: buildpack, Layer: layer, Reused: &reused}
+}
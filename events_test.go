@@ -0,0 +1,75 @@
+package pack_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/logging"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestEvents(t *testing.T) {
+	spec.Run(t, "events", testEvents, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testEvents(t *testing.T, when spec.G, it spec.S) {
+	var buf bytes.Buffer
+
+	it.Before(func() {
+		buf.Reset()
+	})
+
+	decode := func() []pack.Event {
+		var events []pack.Event
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var e pack.Event
+			assertNil(t, json.Unmarshal([]byte(line), &e))
+			events = append(events, e)
+		}
+		return events
+	}
+
+	when("JSONEvents", func() {
+		it("writes one event per line", func() {
+			events := pack.NewJSONEvents(&buf)
+			events.Emit(pack.Event{Type: pack.EventPhaseStart, Phase: "detect"})
+			events.Emit(pack.Event{Type: pack.EventPhaseEnd, Phase: "detect", Duration: 1.5})
+
+			actual := decode()
+			assertEq(t, len(actual), 2)
+			assertEq(t, actual[0].Type, "phase-start")
+			assertEq(t, actual[0].Phase, "detect")
+			assertEq(t, actual[0].Time.IsZero(), false)
+			assertEq(t, actual[1].Duration, 1.5)
+		})
+
+		it("wraps each line written to a stream as a log event", func() {
+			events := pack.NewJSONEvents(&buf)
+			w := events.Writer("stdout")
+			w.Write([]byte("first line\nsecond "))
+			w.Write([]byte("line\nunfinished"))
+
+			actual := decode()
+			assertEq(t, len(actual), 2)
+			assertEq(t, actual[0].Type, "log")
+			assertEq(t, actual[0].Stream, "stdout")
+			assertEq(t, actual[0].Message, "first line")
+			assertEq(t, actual[1].Message, "second line")
+		})
+	})
+
+	when("TextEvents", func() {
+		it("prints phase banners only", func() {
+			events := &pack.TextEvents{Log: logging.New(&buf, &buf, logging.Options{})}
+			events.Emit(pack.Event{Type: pack.EventPhaseStart, Phase: "build", Message: "BUILDING:"})
+			events.Emit(pack.Event{Type: pack.EventPhaseEnd, Phase: "build"})
+			events.Emit(pack.Event{Type: pack.EventPull, Image: "some/image"})
+
+			assertEq(t, buf.String(), "*** BUILDING:\n")
+		})
+	})
+}
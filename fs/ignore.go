@@ -0,0 +1,190 @@
+package fs
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const IgnoreFileName = ".packignore"
+
+// Ignore matches paths relative to the app dir against gitignore style
+// patterns. It also keeps count of everything it caused to be skipped, so
+// callers can report on it once the archive has been written.
+type Ignore struct {
+	patterns     []ignorePattern
+	include      []ignorePattern
+	SkippedFiles int
+	SkippedBytes int64
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func NewIgnore(patterns []string) *Ignore {
+	i := &Ignore{}
+	for _, p := range patterns {
+		if pattern, ok := parseIgnorePattern(p); ok {
+			i.patterns = append(i.patterns, pattern)
+		}
+	}
+	return i
+}
+
+// IncludeOnly leaves out every file that matches none of patterns and is not
+// inside a directory that does. Directories themselves are still walked, so
+// included files further down are found.
+func (i *Ignore) IncludeOnly(patterns []string) {
+	for _, p := range patterns {
+		if pattern, ok := parseIgnorePattern(p); ok {
+			i.include = append(i.include, pattern)
+		}
+	}
+}
+
+// ReadIgnoreFile returns the patterns in the .packignore file of dir, or nil
+// if there is no such file.
+func ReadIgnoreFile(dir string) ([]string, error) {
+	fh, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+// Match reports whether relPath should be left out. As with git, the last
+// pattern that matches wins, so a later "!pattern" re-includes a path. Files
+// not covered by IncludeOnly are left out as well.
+func (i *Ignore) Match(relPath string, isDir bool) bool {
+	if i == nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+	for _, p := range i.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored || !isDir && !i.included(relPath)
+}
+
+func (i *Ignore) included(relPath string) bool {
+	if len(i.include) == 0 {
+		return true
+	}
+	for p := relPath; p != "." && p != "/"; p = path.Dir(p) {
+		included := false
+		for _, pattern := range i.include {
+			if pattern.dirOnly && p == relPath {
+				continue
+			}
+			if pattern.re.MatchString(p) {
+				included = !pattern.negate
+			}
+		}
+		if included {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Ignore) skip(size int64) {
+	if i == nil {
+		return
+	}
+	i.SkippedFiles++
+	i.SkippedBytes += size
+}
+
+func parseIgnorePattern(p string) (ignorePattern, bool) {
+	p = strings.TrimRight(p, " \t\r")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return ignorePattern{}, false
+	}
+
+	var pattern ignorePattern
+	if strings.HasPrefix(p, "!") {
+		pattern.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\`) {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		pattern.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return ignorePattern{}, false
+	}
+
+	// patterns containing a slash are relative to the app dir, others match at any depth
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if strings.HasPrefix(p[i:], "**/") {
+				re.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(p[i:], "**") {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(p[i:], ']'); end > 0 {
+				class := p[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				re.WriteString("[" + class + "]")
+				i += end
+			} else {
+				re.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		case '\\':
+			if i+1 < len(p) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(p[i])))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	var err error
+	if pattern.re, err = regexp.Compile(re.String()); err != nil {
+		return ignorePattern{}, false
+	}
+	return pattern, true
+}
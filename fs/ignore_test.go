@@ -0,0 +1,132 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpack/pack/fs"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestIgnore(t *testing.T) {
+	spec.Run(t, "ignore", testIgnore, spec.Report(report.Terminal{}))
+}
+
+func testIgnore(t *testing.T, when spec.G, it spec.S) {
+	when("#Match", func() {
+		var ignore *fs.Ignore
+
+		it.Before(func() {
+			ignore = fs.NewIgnore([]string{
+				"# a comment",
+				"node_modules/",
+				"*.log",
+				"!keep.log",
+				"/build",
+				"docs/**/*.md",
+			})
+		})
+
+		for _, tc := range []struct {
+			path    string
+			isDir   bool
+			ignored bool
+		}{
+			{"node_modules", true, true},
+			{"src/node_modules", true, true},
+			{"node_modules", false, false},
+			{"app.log", false, true},
+			{"logs/app.log", false, true},
+			{"keep.log", false, false},
+			{"logs/keep.log", false, false},
+			{"build", true, true},
+			{"src/build", true, false},
+			{"docs/a/b/readme.md", false, true},
+			{"readme.md", false, false},
+		} {
+			tc := tc
+			it("matches "+tc.path, func() {
+				if actual := ignore.Match(tc.path, tc.isDir); actual != tc.ignored {
+					t.Fatalf("expected Match(%q, %t) to be %t, got %t", tc.path, tc.isDir, tc.ignored, actual)
+				}
+			})
+		}
+
+		when("include patterns are given", func() {
+			it.Before(func() {
+				ignore.IncludeOnly([]string{"src/", "*.json"})
+			})
+
+			for _, tc := range []struct {
+				path    string
+				isDir   bool
+				ignored bool
+			}{
+				{"src", true, false},
+				{"src/main.go", false, false},
+				{"src/app.log", false, true},
+				{"package.json", false, false},
+				{"test", true, false},
+				{"test/main_test.go", false, true},
+				{"test/fixture.json", false, false},
+				{"readme.md", false, true},
+			} {
+				tc := tc
+				it("matches "+tc.path, func() {
+					if actual := ignore.Match(tc.path, tc.isDir); actual != tc.ignored {
+						t.Fatalf("expected Match(%q, %t) to be %t, got %t", tc.path, tc.isDir, tc.ignored, actual)
+					}
+				})
+			}
+		})
+
+		it("matches nothing when nil", func() {
+			var nilIgnore *fs.Ignore
+			if nilIgnore.Match("anything", false) {
+				t.Fatal("expected nil ignore to match nothing")
+			}
+		})
+	})
+
+	when("#ReadIgnoreFile", func() {
+		var tmpDir string
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "pack.ignore.test.")
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		it("returns nil when there is no .packignore", func() {
+			patterns, err := fs.ReadIgnoreFile(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if patterns != nil {
+				t.Fatalf("expected no patterns, got %v", patterns)
+			}
+		})
+
+		it("returns the lines of .packignore", func() {
+			if err := ioutil.WriteFile(filepath.Join(tmpDir, ".packignore"), []byte(".git/\n!keep.log\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+			patterns, err := fs.ReadIgnoreFile(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(patterns) != 2 || patterns[0] != ".git/" || patterns[1] != "!keep.log" {
+				t.Fatalf("unexpected patterns %v", patterns)
+			}
+		})
+	})
+}
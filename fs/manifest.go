@@ -0,0 +1,191 @@
+package fs
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestEntry is a file or directory as it was last copied.
+type ManifestEntry struct {
+	Mode    os.FileMode `json:"mode"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	// Hash is the sha256 of a regular file or the target of a symlink
+	Hash string `json:"hash,omitempty"`
+}
+
+// Manifest records the contents of a directory, by slash separated path
+// relative to it, "." being the directory itself.
+type Manifest map[string]ManifestEntry
+
+// Changes walks srcDir and compares it with previous. It returns the manifest
+// of srcDir now, the paths that are new or changed, parents first, and the
+// paths that are gone. A file with nothing but a new mtime is compared by
+// hash, so touching it does not make it changed. Paths matched by ignore count
+// as gone and are counted on it; ignore may be nil. When previous is nil
+// everything is new and no hashes are computed.
+func Changes(srcDir string, ignore *Ignore, previous Manifest) (Manifest, []string, []string, error) {
+	current := Manifest{}
+	var changed []string
+	err := filepath.Walk(srcDir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, file)
+		if err != nil {
+			return err
+		}
+		if relPath != "." && ignore.Match(relPath, fi.IsDir()) {
+			if fi.IsDir() {
+				if err := countSkipped(file, ignore); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			ignore.skip(fi.Size())
+			return nil
+		}
+
+		path := filepath.ToSlash(relPath)
+		entry := ManifestEntry{Mode: fi.Mode(), ModTime: fi.ModTime().UTC()}
+		if fi.Mode().IsRegular() {
+			entry.Size = fi.Size()
+		}
+		prev, ok := previous[path]
+		switch {
+		case previous == nil:
+			changed = append(changed, path)
+		case !ok || prev.Mode != entry.Mode || prev.Size != entry.Size:
+			if entry.Hash, err = hashFile(file, fi); err != nil {
+				return err
+			}
+			changed = append(changed, path)
+		case fi.IsDir() || prev.ModTime.Equal(entry.ModTime):
+			entry.Hash = prev.Hash
+		default:
+			if entry.Hash, err = hashFile(file, fi); err != nil {
+				return err
+			}
+			if entry.Hash != prev.Hash {
+				changed = append(changed, path)
+			}
+		}
+		current[path] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var removed []string
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	// what was inside a removed directory goes with it
+	var topRemoved []string
+	for _, path := range removed {
+		if n := len(topRemoved); n == 0 || !strings.HasPrefix(path, topRemoved[n-1]+"/") {
+			topRemoved = append(topRemoved, path)
+		}
+	}
+	return current, changed, topRemoved, nil
+}
+
+func hashFile(file string, fi os.FileInfo) (string, error) {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return os.Readlink(file)
+	}
+	if !fi.Mode().IsRegular() {
+		return "", nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+func countSkipped(dir string, ignore *Ignore) error {
+	return filepath.Walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			ignore.skip(fi.Size())
+		}
+		return nil
+	})
+}
+
+// CreateFilesTarReader streams the given paths of srcDir, as returned by
+// Changes, as a tar rooted at tarDir. Unlike CreateTarReader it includes
+// directories, so that every entry, tarDir too, is owned by uid and gid.
+func (*FS) CreateFilesTarReader(srcDir, tarDir string, paths []string, uid, gid int) (io.Reader, chan error) {
+	r, w := io.Pipe()
+	errChan := make(chan error, 1)
+
+	go func() {
+		err := writeFilesTar(w, srcDir, tarDir, paths, uid, gid)
+		w.CloseWithError(err)
+		errChan <- err
+	}()
+	return r, errChan
+}
+
+func writeFilesTar(w io.Writer, srcDir, tarDir string, paths []string, uid, gid int) error {
+	tw := tar.NewWriter(w)
+	for _, path := range paths {
+		file := filepath.Join(srcDir, filepath.FromSlash(path))
+		fi, err := os.Lstat(file)
+		if err != nil {
+			return err
+		}
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(tarDir, path))
+		header.Uid = uid
+		header.Gid = gid
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			if err := copyFile(tw, file); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func copyFile(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,160 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/buildpack/pack/fs"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestManifest(t *testing.T) {
+	spec.Run(t, "manifest", testManifest, spec.Report(report.Terminal{}))
+}
+
+func testManifest(t *testing.T, when spec.G, it spec.S) {
+	var src string
+
+	writeFile := func(path, txt string) {
+		t.Helper()
+		path = filepath.Join(src, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(txt), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes := func(previous fs.Manifest) (fs.Manifest, []string, []string) {
+		t.Helper()
+		current, changed, removed, err := fs.Changes(src, nil, previous)
+		if err != nil {
+			t.Fatalf("Changes failed: %s", err)
+		}
+		return current, changed, removed
+	}
+
+	assertPaths := func(actual, expected []string) {
+		t.Helper()
+		if len(actual) == 0 && len(expected) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+
+	it.Before(func() {
+		var err error
+		src, err = ioutil.TempDir("", "manifest-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile("app.js", "app")
+		writeFile("lib/util.js", "util")
+		writeFile("old/stale.js", "stale")
+	})
+
+	it.After(func() {
+		os.RemoveAll(src)
+	})
+
+	when("#Changes", func() {
+		it("counts everything as new without a previous manifest", func() {
+			current, changed, removed := changes(nil)
+			assertPaths(changed, []string{".", "app.js", "lib", "lib/util.js", "old", "old/stale.js"})
+			assertPaths(removed, nil)
+			if current["app.js"].Size != 3 {
+				t.Fatalf("expected app.js to be recorded with size 3, got %+v", current["app.js"])
+			}
+		})
+
+		it("finds added, changed and removed paths", func() {
+			previous, _, _ := changes(fs.Manifest{})
+			writeFile("app.js", "changed app")
+			writeFile("lib/new.js", "new")
+			if err := os.RemoveAll(filepath.Join(src, "old")); err != nil {
+				t.Fatal(err)
+			}
+
+			_, changed, removed := changes(previous)
+			assertPaths(changed, []string{"app.js", "lib/new.js"})
+			assertPaths(removed, []string{"old"})
+		})
+
+		it("does not count a file with only a new mtime as changed", func() {
+			previous, _, _ := changes(fs.Manifest{})
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(src, "app.js"), later, later); err != nil {
+				t.Fatal(err)
+			}
+
+			current, changed, _ := changes(previous)
+			assertPaths(changed, nil)
+			if !current["app.js"].ModTime.Equal(later) {
+				t.Fatalf("expected the new mtime to be recorded, got %s", current["app.js"].ModTime)
+			}
+		})
+
+		it("counts a file with the same size but other contents as changed", func() {
+			previous, _, _ := changes(fs.Manifest{})
+			writeFile("app.js", "APP")
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(src, "app.js"), later, later); err != nil {
+				t.Fatal(err)
+			}
+
+			_, changed, _ := changes(previous)
+			assertPaths(changed, []string{"app.js"})
+		})
+
+		it("treats ignored paths as removed and counts them", func() {
+			previous, _, _ := changes(fs.Manifest{})
+			ignore := fs.NewIgnore([]string{"old/"})
+
+			_, changed, removed, err := fs.Changes(src, ignore, previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertPaths(changed, nil)
+			assertPaths(removed, []string{"old"})
+			if ignore.SkippedFiles != 1 {
+				t.Fatalf("expected 1 skipped file, got %d", ignore.SkippedFiles)
+			}
+		})
+	})
+
+	when("#CreateFilesTarReader", func() {
+		it("writes the given paths, directories included, owned by uid and gid", func() {
+			r, errChan := (&fs.FS{}).CreateFilesTarReader(src, "/workspace/app", []string{".", "lib", "lib/util.js"}, 1234, 2345)
+			tr := tar.NewReader(r)
+
+			var names []string
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to get next file: %s", err)
+				}
+				if header.Uid != 1234 || header.Gid != 2345 {
+					t.Fatalf("expected %s to be owned by 1234:2345, was %d:%d", header.Name, header.Uid, header.Gid)
+				}
+				names = append(names, header.Name)
+			}
+			if err := <-errChan; err != nil {
+				t.Fatalf("CreateFilesTarReader failed: %s", err)
+			}
+			assertPaths(names, []string{"/workspace/app", "/workspace/app/lib", "/workspace/app/lib/util.js"})
+		})
+	})
+}
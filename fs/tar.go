@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type FS struct {
@@ -118,6 +119,12 @@ func (*FS) Untar(r io.Reader, dest string) error {
 		}
 
 		path := filepath.Join(dest, hdr.Name)
+		if !within(dest, path) {
+			return fmt.Errorf("illegal file path in tar: %s", hdr.Name)
+		}
+		if err := checkNoSymlinks(dest, path); err != nil {
+			return fmt.Errorf("illegal file path in tar: %s: %s", hdr.Name, err)
+		}
 
 		switch hdr.Typeflag {
 		case tar.TypeDir:
@@ -142,11 +149,45 @@ func (*FS) Untar(r io.Reader, dest string) error {
 			}
 			fh.Close()
 		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
 			if err := os.Symlink(hdr.Linkname, path); err != nil {
 				return err
 			}
+		case tar.TypeXGlobalHeader:
+			// pax global header (e.g. from git archive), carries no file
 		default:
 			return fmt.Errorf("unknown file type in tar %d", hdr.Typeflag)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func within(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// checkNoSymlinks fails if path or any of its parents below dest already is a
+// symlink, so an archive cannot write outside dest through a link it created.
+func checkNoSymlinks(dest, path string) error {
+	rel, err := filepath.Rel(dest, path)
+	if err != nil || rel == "." {
+		return err
+	}
+	current := filepath.Clean(dest)
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		current = filepath.Join(current, part)
+		fi, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(dest, current)
+			return fmt.Errorf("'%s' is a symlink", filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
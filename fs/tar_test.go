@@ -2,6 +2,7 @@ package fs_test
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"io/ioutil"
 	"math/rand"
@@ -93,4 +94,56 @@ func testFS(t *testing.T, when spec.G, it spec.S) {
 			t.Fatalf(`expected to link-file to have atrget "../some-file.txt" got %s`, header.Linkname)
 		}
 	})
+	it("refuses to untar paths outside the dest dir", func() {
+		tarFile := filepath.Join(tmpDir, "evil.tar")
+		fh, err := os.Create(tarFile)
+		if err != nil {
+			t.Fatalf("failed to create tar: %s", err)
+		}
+		tw := tar.NewWriter(fh)
+		if err := tw.WriteHeader(&tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644}); err != nil {
+			t.Fatalf("failed to write header: %s", err)
+		}
+		tw.Close()
+		fh.Close()
+
+		r, err := os.Open(tarFile)
+		if err != nil {
+			t.Fatalf("failed to open tar: %s", err)
+		}
+		defer r.Close()
+
+		err = fs.Untar(r, filepath.Join(tmpDir, "dest"))
+		if err == nil || err.Error() != "illegal file path in tar: ../evil.txt" {
+			t.Fatalf(`expected illegal file path error, got %v`, err)
+		}
+	})
+	it("refuses to untar through a symlink out of the dest dir", func() {
+		outside, dest := filepath.Join(tmpDir, "outside"), filepath.Join(tmpDir, "dest")
+		for _, dir := range []string{outside, dest} {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatalf("failed to create dir: %s", err)
+			}
+		}
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside}); err != nil {
+			t.Fatalf("failed to write header: %s", err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: "a/.bashrc", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}); err != nil {
+			t.Fatalf("failed to write header: %s", err)
+		}
+		if _, err := tw.Write([]byte("evil")); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+		tw.Close()
+
+		err := fs.Untar(&buf, dest)
+		if err == nil || err.Error() != "illegal file path in tar: a/.bashrc: 'a' is a symlink" {
+			t.Fatalf(`expected illegal file path error, got %v`, err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, ".bashrc")); !os.IsNotExist(err) {
+			t.Fatalf("expected nothing to be written outside the dest dir, got: %v", err)
+		}
+	})
 }
@@ -0,0 +1,382 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	OCILayout     = "oci"
+	DockerArchive = "docker-archive"
+
+	// ociRefName is the annotation naming a manifest in an OCI layout index
+	ociRefName = "org.opencontainers.image.ref.name"
+)
+
+// FileStore keeps an image in a file rather than a registry or daemon, either
+// an OCI image layout directory or a tarball for `docker load`. It is named
+// like "oci:<dir>[:<image name>]" or "docker-archive:<file>[:<image name>]".
+type FileStore struct {
+	Format string
+	Path   string
+	// Name is the image name recorded in the file, may be empty
+	Name string
+}
+
+// IsFileRef is true for a reference to a FileStore rather than an image name.
+func IsFileRef(ref string) bool {
+	return strings.HasPrefix(ref, OCILayout+":") || strings.HasPrefix(ref, DockerArchive+":")
+}
+
+func NewFileStore(ref string) (*FileStore, error) {
+	parts := strings.SplitN(ref, ":", 3)
+	if len(parts) < 2 || (parts[0] != OCILayout && parts[0] != DockerArchive) || parts[1] == "" {
+		return nil, fmt.Errorf("invalid image file '%s': must be oci:<dir> or docker-archive:<file>", ref)
+	}
+	s := &FileStore{Format: parts[0], Path: parts[1]}
+	if len(parts) == 3 {
+		s.Name = parts[2]
+		if _, err := name.ParseReference(s.Name, name.WeakValidation); err != nil {
+			return nil, errors.Wrapf(err, "invalid image name in '%s'", ref)
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) String() string {
+	if s.Name == "" {
+		return s.Format + ":" + s.Path
+	}
+	return s.Format + ":" + s.Path + ":" + s.Name
+}
+
+func (s *FileStore) Ref() name.Reference {
+	ref, _ := name.ParseReference(s.Name, name.WeakValidation)
+	return ref
+}
+
+// Image reads the image from the file, an error satisfying os.IsNotExist is
+// returned when there is no file yet.
+func (s *FileStore) Image() (v1.Image, error) {
+	if _, err := os.Stat(s.Path); err != nil {
+		return nil, err
+	}
+	if s.Format == DockerArchive {
+		return tarball.ImageFromPath(s.Path, nil)
+	}
+	return s.readLayout()
+}
+
+func (s *FileStore) Write(image v1.Image) error {
+	if s.Format == DockerArchive {
+		return s.writeArchive(image)
+	}
+	return s.writeLayout(image)
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociDescriptor struct {
+	MediaType   types.MediaType   `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (s *FileStore) blobPath(digest string) string {
+	return filepath.Join(s.Path, "blobs", strings.Replace(digest, ":", string(filepath.Separator), 1))
+}
+
+func (s *FileStore) readLayout() (v1.Image, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(s.Path, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	var index ociIndex
+	if err := json.Unmarshal(buf, &index); err != nil {
+		return nil, errors.Wrapf(err, "parse index of '%s'", s.Path)
+	}
+	for _, m := range index.Manifests {
+		if s.Name == "" || m.Annotations[ociRefName] == s.Name {
+			return partial.CompressedToImage(&layoutImage{store: s, manifest: m})
+		}
+	}
+	return nil, &os.PathError{Op: "read image", Path: s.String(), Err: os.ErrNotExist}
+}
+
+// layoutImage reads an image from the blobs of an OCI layout as they are
+// needed, partial fills in the rest of v1.Image.
+type layoutImage struct {
+	store    *FileStore
+	manifest ociDescriptor
+}
+
+func (i *layoutImage) MediaType() (types.MediaType, error) {
+	return i.manifest.MediaType, nil
+}
+
+func (i *layoutImage) RawManifest() ([]byte, error) {
+	return ioutil.ReadFile(i.store.blobPath(i.manifest.Digest))
+}
+
+func (i *layoutImage) RawConfigFile() ([]byte, error) {
+	raw, err := i.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(i.store.blobPath(manifest.Config.Digest.String()))
+}
+
+func (i *layoutImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	return &layoutLayer{path: i.store.blobPath(h.String()), digest: h}, nil
+}
+
+type layoutLayer struct {
+	path   string
+	digest v1.Hash
+}
+
+func (l *layoutLayer) Digest() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *layoutLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.path)
+}
+
+func (l *layoutLayer) Size() (int64, error) {
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// writeLayout adds the image to the layout and points the index at it. Blobs
+// already in the layout, like the layers of a previous build, are kept.
+func (s *FileStore) writeLayout(image v1.Image) error {
+	if err := os.MkdirAll(s.Path, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.Path, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644); err != nil {
+		return err
+	}
+	err := eachBlob(image, func(digest string, open func() (io.ReadCloser, error)) error {
+		path := s.blobPath(digest)
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		return writeFile(path, open)
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest, err := image.RawManifest()
+	if err != nil {
+		return err
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return err
+	}
+	mediaType, err := image.MediaType()
+	if err != nil {
+		return err
+	}
+	if err := writeFile(s.blobPath(digest.String()), bytesOpener(manifest)); err != nil {
+		return err
+	}
+	desc := ociDescriptor{MediaType: mediaType, Digest: digest.String(), Size: int64(len(manifest))}
+	if s.Name != "" {
+		desc.Annotations = map[string]string{ociRefName: s.Name}
+	}
+
+	index := ociIndex{SchemaVersion: 2}
+	if buf, err := ioutil.ReadFile(filepath.Join(s.Path, "index.json")); err == nil {
+		if err := json.Unmarshal(buf, &index); err != nil {
+			return errors.Wrapf(err, "parse index of '%s'", s.Path)
+		}
+	}
+	var manifests []ociDescriptor
+	for _, m := range index.Manifests {
+		// an unnamed image, or one by the same name, is replaced
+		if s.Name != "" && m.Annotations[ociRefName] != "" && m.Annotations[ociRefName] != s.Name {
+			manifests = append(manifests, m)
+		}
+	}
+	index.Manifests = append(manifests, desc)
+	buf, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.Path, "index.json"), bytesOpener(buf))
+}
+
+// writeArchive writes the image in the format of `docker save`. The previous
+// image may be read from the same file while this runs, so it is replaced only
+// once complete.
+func (s *FileStore) writeArchive(image v1.Image) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	manifest, err := image.Manifest()
+	if err != nil {
+		return err
+	}
+	entry := struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}{Config: manifest.Config.Digest.String()}
+	if s.Name != "" {
+		ref, err := name.ParseReference(s.Name, name.WeakValidation)
+		if err != nil {
+			return err
+		}
+		if tag, ok := ref.(name.Tag); ok {
+			repoTag := s.Name
+			if !strings.HasSuffix(repoTag, ":"+tag.TagStr()) {
+				repoTag += ":" + tag.TagStr()
+			}
+			entry.RepoTags = []string{repoTag}
+		}
+	}
+	for _, l := range manifest.Layers {
+		entry.Layers = append(entry.Layers, l.Digest.Hex+".tar.gz")
+	}
+
+	tw := tar.NewWriter(tmp)
+	err = eachBlob(image, func(digest string, open func() (io.ReadCloser, error)) error {
+		file := strings.TrimPrefix(digest, "sha256:") + ".tar.gz"
+		if digest == manifest.Config.Digest.String() {
+			file = digest
+		}
+		return addToTar(tw, file, open)
+	})
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal([]interface{}{entry})
+	if err != nil {
+		return err
+	}
+	if err := addToTar(tw, "manifest.json", bytesOpener(buf)); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// eachBlob calls fn with the config and the compressed layers of image.
+func eachBlob(image v1.Image, fn func(digest string, open func() (io.ReadCloser, error)) error) error {
+	config, err := image.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	configName, err := image.ConfigName()
+	if err != nil {
+		return err
+	}
+	if err := fn(configName.String(), bytesOpener(config)); err != nil {
+		return err
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		digest, err := l.Digest()
+		if err != nil {
+			return err
+		}
+		if err := fn(digest.String(), l.Compressed); err != nil {
+			return errors.Wrapf(err, "write layer %s", digest)
+		}
+	}
+	return nil
+}
+
+func bytesOpener(buf []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf)), nil
+	}
+}
+
+func writeFile(path string, open func() (io.ReadCloser, error)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addToTar adds a file to tw. Its size has to be known up front, so the
+// contents go through a temp file first.
+func addToTar(tw *tar.Writer, name string, open func() (io.ReadCloser, error)) error {
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tmp, err := ioutil.TempFile("", "pack.blob.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, rc)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, tmp)
+	return err
+}
@@ -0,0 +1,157 @@
+package image_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpack/pack/image"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestFileStore(t *testing.T) {
+	spec.Run(t, "file store", testFileStore, spec.Report(report.Terminal{}))
+}
+
+func testFileStore(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.image.test.")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	assertSameImage := func(actual, expected v1.Image) {
+		t.Helper()
+		actualConfig, err := actual.ConfigName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedConfig, err := expected.ConfigName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actualConfig != expectedConfig {
+			t.Fatalf("expected config %s, got %s", expectedConfig, actualConfig)
+		}
+		actualLayers, err := actual.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedLayers, err := expected.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actualLayers) != len(expectedLayers) {
+			t.Fatalf("expected %d layers, got %d", len(expectedLayers), len(actualLayers))
+		}
+		for i := range actualLayers {
+			actualDigest, _ := actualLayers[i].Digest()
+			expectedDigest, _ := expectedLayers[i].Digest()
+			if actualDigest != expectedDigest {
+				t.Fatalf("expected layer %d to be %s, got %s", i, expectedDigest, actualDigest)
+			}
+		}
+	}
+
+	for _, format := range []string{image.OCILayout, image.DockerArchive} {
+		format := format
+		when(format, func() {
+			var ref string
+
+			it.Before(func() {
+				ref = format + ":" + filepath.Join(tmpDir, "app") + ":some/app:latest"
+			})
+
+			it("writes an image that reads back the same", func() {
+				expected, err := random.Image(1024, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				store, err := (&image.Client{}).RepoStore(ref, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.Write(expected); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				actual, err := (&image.Client{}).ReadImage(ref, true)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if actual == nil {
+					t.Fatal("expected an image")
+				}
+				assertSameImage(actual, expected)
+			})
+
+			it("replaces a previous image", func() {
+				store, err := image.NewFileStore(ref)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, layers := range []int64{1, 3} {
+					i, err := random.Image(1024, layers)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := store.Write(i); err != nil {
+						t.Fatalf("unexpected error: %s", err)
+					}
+				}
+
+				actual, err := store.Image()
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				layers, err := actual.Layers()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(layers) != 3 {
+					t.Fatalf("expected the second image with 3 layers, got %d", len(layers))
+				}
+			})
+
+			it("reads nothing when there is no file yet", func() {
+				actual, err := (&image.Client{}).ReadImage(ref, false)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if actual != nil {
+					t.Fatal("expected no image")
+				}
+			})
+		})
+	}
+
+	when("#NewFileStore", func() {
+		it("parses the format, path and image name", func() {
+			store, err := image.NewFileStore("oci:/some/dir:some/app:latest")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if store.Format != image.OCILayout || store.Path != "/some/dir" || store.Name != "some/app:latest" {
+				t.Fatalf("unexpected store %+v", store)
+			}
+		})
+
+		it("returns an error for an unknown format", func() {
+			if _, err := image.NewFileStore("tar:/some/app.tar"); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	})
+}
@@ -1,14 +1,69 @@
 package image
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/buildpack/lifecycle/img"
+	"github.com/buildpack/pack/auth"
 	"github.com/buildpack/packs"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
 )
 
+func init() {
+	// the lifecycle registry store resolves credentials with the default
+	// keychain, this makes it honour credHelpers and credsStore too
+	authn.DefaultKeychain = auth.Keychain{}
+}
+
 type Client struct{}
 
+// AuthError is returned when a registry refuses access to an image, which is
+// reported as such rather than as the image not existing.
+type AuthError struct {
+	Ref string
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("not authorized to access image '%s', check your credentials (docker login): %s", e.Ref, e.Err)
+}
+
+// RegistryError sorts out an error from reading ref in a registry. It returns
+// nil if the image does not exist, an *AuthError if access was refused and
+// err itself otherwise.
+func RegistryError(ref string, err error) error {
+	if remoteErr, ok := err.(*remote.Error); ok && len(remoteErr.Errors) > 0 {
+		switch remoteErr.Errors[0].Code {
+		case remote.ManifestUnknownErrorCode, remote.NameUnknownErrorCode:
+			return nil
+		case remote.UnauthorizedErrorCode, remote.DeniedErrorCode:
+			return &AuthError{Ref: ref, Err: err}
+		}
+	}
+	return err
+}
+
+// ReadImage returns the image, or nil if it does not exist. repoName may also
+// name an image file, see FileStore, which is read regardless of useDaemon.
 func (c *Client) ReadImage(repoName string, useDaemon bool) (v1.Image, error) {
+	if IsFileRef(repoName) {
+		store, err := NewFileStore(repoName)
+		if err != nil {
+			return nil, err
+		}
+		image, err := store.Image()
+		if os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "read image file '%s'", repoName)
+		}
+		return image, nil
+	}
+
 	repoStore, err := c.RepoStore(repoName, useDaemon)
 	if err != nil {
 		return nil, err
@@ -16,19 +71,27 @@ func (c *Client) ReadImage(repoName string, useDaemon bool) (v1.Image, error) {
 
 	origImage, err := repoStore.Image()
 	if err != nil {
-		// Assume error is due to non-existent image
-		return nil, nil
+		if useDaemon {
+			// Assume error is due to non-existent image
+			return nil, nil
+		}
+		return nil, RegistryError(repoName, err)
 	}
 	if _, err := origImage.RawManifest(); err != nil {
-		// Assume error is due to non-existent image
-		// This is necessary for registries
-		return nil, nil
+		if useDaemon {
+			return nil, nil
+		}
+		// Registries only report a missing image once the manifest is read
+		return nil, RegistryError(repoName, err)
 	}
 
 	return origImage, nil
 }
 
 func (c *Client) RepoStore(repoName string, useDaemon bool) (img.Store, error) {
+	if IsFileRef(repoName) {
+		return NewFileStore(repoName)
+	}
 	newRepoStore := img.NewRegistry
 	if useDaemon {
 		newRepoStore = img.NewDaemon
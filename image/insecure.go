@@ -0,0 +1,119 @@
+package image
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+)
+
+// UseInsecureRegistries lets registry access reach the given hosts over HTTPS
+// without verifying certificates, falling back to plain HTTP when they do not
+// speak TLS at all. As with the docker daemon's insecure-registries, host and
+// port must match exactly, so a host without a port only matches requests to
+// it without one.
+//
+// The lifecycle registry store always uses http.DefaultTransport, so this
+// replaces it for the whole process. It is meant to be called once by the CLI
+// before any registry access.
+func UseInsecureRegistries(registries []string) {
+	if len(registries) == 0 {
+		return
+	}
+	base := http.DefaultTransport
+	if t, ok := base.(*insecureTransport); ok {
+		base = t.base
+	}
+	http.DefaultTransport = NewInsecureTransport(registries, base)
+}
+
+// NewInsecureTransport wraps base so that the given registries are treated as
+// described for UseInsecureRegistries.
+func NewInsecureTransport(registries []string, base http.RoundTripper) http.RoundTripper {
+	t := &insecureTransport{
+		base:       base,
+		registries: map[string]bool{},
+		plainHTTP:  map[string]bool{},
+	}
+	for _, r := range registries {
+		t.registries[r] = true
+	}
+	skipVerify := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	if b, ok := base.(*http.Transport); ok {
+		// keep proxy and timeout settings
+		skipVerify = cloneTransport(b)
+		skipVerify.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	t.skipVerify = skipVerify
+	return t
+}
+
+type insecureTransport struct {
+	base       http.RoundTripper
+	skipVerify http.RoundTripper
+	registries map[string]bool
+
+	mu        sync.Mutex
+	plainHTTP map[string]bool // insecure hosts found not to speak TLS
+}
+
+func (t *insecureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if !t.registries[host] {
+		return t.base.RoundTrip(req)
+	}
+	if req.URL.Scheme == "http" || t.usesPlainHTTP(host) {
+		return t.base.RoundTrip(withScheme(req, "http"))
+	}
+
+	resp, httpsErr := t.skipVerify.RoundTrip(req)
+	if httpsErr == nil || (req.Body != nil && req.GetBody == nil) {
+		// a request body that was already sent cannot be sent again
+		return resp, httpsErr
+	}
+	httpReq := withScheme(req, "http")
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Body = body
+	}
+	resp, err := t.base.RoundTrip(httpReq)
+	if err != nil {
+		// report why HTTPS failed, plain HTTP was only a fallback
+		return nil, httpsErr
+	}
+	t.mu.Lock()
+	t.plainHTTP[host] = true
+	t.mu.Unlock()
+	return resp, nil
+}
+
+func (t *insecureTransport) usesPlainHTTP(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.plainHTTP[host]
+}
+
+func withScheme(req *http.Request, scheme string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	u.Scheme = scheme
+	r.URL = &u
+	return r
+}
+
+func cloneTransport(t *http.Transport) *http.Transport {
+	return &http.Transport{
+		Proxy:                 t.Proxy,
+		DialContext:           t.DialContext,
+		MaxIdleConns:          t.MaxIdleConns,
+		IdleConnTimeout:       t.IdleConnTimeout,
+		TLSHandshakeTimeout:   t.TLSHandshakeTimeout,
+		ExpectContinueTimeout: t.ExpectContinueTimeout,
+	}
+}
@@ -0,0 +1,102 @@
+package image_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/buildpack/pack/image"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestInsecureTransport(t *testing.T) {
+	spec.Run(t, "insecure transport", testInsecureTransport, spec.Report(report.Terminal{}))
+}
+
+func testInsecureTransport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tlsServer, plainServer *httptest.Server
+		tlsHost, plainHost     string
+	)
+
+	it.Before(func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+		tlsServer = httptest.NewTLSServer(handler)
+		plainServer = httptest.NewServer(handler)
+		tlsHost = host(t, tlsServer.URL)
+		plainHost = host(t, plainServer.URL)
+	})
+
+	it.After(func() {
+		tlsServer.Close()
+		plainServer.Close()
+	})
+
+	get := func(transport http.RoundTripper, host string) error {
+		resp, err := (&http.Client{Transport: transport}).Get("https://" + host + "/v2/")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	when("the registry is insecure", func() {
+		it("skips verification of a self-signed certificate", func() {
+			transport := image.NewInsecureTransport([]string{tlsHost}, http.DefaultTransport)
+			if err := get(transport, tlsHost); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+
+		it("falls back to plain HTTP", func() {
+			transport := image.NewInsecureTransport([]string{plainHost}, http.DefaultTransport)
+			if err := get(transport, plainHost); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := get(transport, plainHost); err != nil {
+				t.Fatalf("unexpected error on second request: %s", err)
+			}
+		})
+
+	})
+
+	when("the registry is not insecure", func() {
+		it("does not match a host without a port on another port", func() {
+			transport := image.NewInsecureTransport([]string{"127.0.0.1"}, http.DefaultTransport)
+			if err := get(transport, tlsHost); err == nil {
+				t.Fatal("expected a certificate error")
+			}
+		})
+
+		it("verifies the certificate", func() {
+			transport := image.NewInsecureTransport([]string{"other.example.com"}, http.DefaultTransport)
+			if err := get(transport, tlsHost); err == nil {
+				t.Fatal("expected a certificate error")
+			}
+		})
+
+		it("does not fall back to plain HTTP", func() {
+			transport := image.NewInsecureTransport([]string{"other.example.com"}, http.DefaultTransport)
+			if err := get(transport, plainHost); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	})
+}
+
+func host(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
@@ -0,0 +1,105 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// LocalBuildpack is a buildpack passed to --buildpack as a directory or
+// archive. It is copied into the detect and build containers of a single
+// build, the builder image is left as is.
+type LocalBuildpack struct {
+	ID      string
+	Version string
+	Dir     string
+}
+
+// isLocalBuildpack tells paths apart from id@version references, which never
+// contain a slash.
+func isLocalBuildpack(ref string) bool {
+	return ref == "." || strings.ContainsRune(ref, '/') || strings.ContainsRune(ref, filepath.Separator) || archiveExt(ref) != ""
+}
+
+// resolveBuildpacks reads the buildpack.toml of every local buildpack in refs
+// and replaces it with its id@version, so it ends up in the order like any
+// buildpack from the builder. The returned func removes extracted archives.
+func resolveBuildpacks(fs FS, refs []string) ([]string, []LocalBuildpack, func(), error) {
+	var tmpDir string
+	cleanup := func() {
+		if tmpDir != "" {
+			os.RemoveAll(tmpDir)
+		}
+	}
+
+	var resolved []string
+	var local []LocalBuildpack
+	for _, ref := range refs {
+		if !isLocalBuildpack(ref) {
+			resolved = append(resolved, ref)
+			continue
+		}
+		dir := ref
+		if archiveExt(ref) != "" {
+			var err error
+			if tmpDir == "" {
+				if tmpDir, err = ioutil.TempDir("", "pack.buildpacks."); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			if dir, err = ioutil.TempDir(tmpDir, "buildpack"); err != nil {
+				cleanup()
+				return nil, nil, nil, err
+			}
+			if err := extractArchive(fs, ref, dir); err != nil {
+				cleanup()
+				return nil, nil, nil, errors.Wrapf(err, "extract buildpack '%s'", ref)
+			}
+		}
+		bp, err := readLocalBuildpack(dir)
+		if err != nil {
+			cleanup()
+			return nil, nil, nil, errors.Wrapf(err, "buildpack '%s'", ref)
+		}
+		resolved = append(resolved, bp.ID+"@"+bp.Version)
+		local = append(local, bp)
+	}
+	return resolved, local, cleanup, nil
+}
+
+func readLocalBuildpack(dir string) (LocalBuildpack, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return LocalBuildpack{}, err
+	}
+	var data BuildpackData
+	if _, err := toml.DecodeFile(filepath.Join(dir, "buildpack.toml"), &data); err != nil {
+		return LocalBuildpack{}, errors.Wrap(err, "read buildpack.toml")
+	}
+	if data.BP.ID == "" || data.BP.Version == "" {
+		return LocalBuildpack{}, fmt.Errorf("buildpack.toml must provide id and version")
+	}
+	return LocalBuildpack{ID: data.BP.ID, Version: data.BP.Version, Dir: dir}, nil
+}
+
+// copyLocalBuildpacks puts the local buildpacks where the lifecycle looks for
+// buildpacks, in the given container only.
+func (b *BuildConfig) copyLocalBuildpacks(ctx context.Context, ctrID string) error {
+	for _, bp := range b.LocalBuildpacks {
+		tr, errChan := b.FS.CreateTarReader(bp.Dir, filepath.Join("/buildpacks", bp.ID, bp.Version), 0, 0)
+		if err := b.Cli.CopyToContainer(ctx, ctrID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
+			return errors.Wrapf(err, "copy buildpack '%s' to container", bp.ID)
+		}
+		if err := <-errChan; err != nil {
+			return errors.Wrapf(err, "copy buildpack '%s' to container", bp.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Logger is where pack reports to the user. Library callers can pass their
+// own, or one from New writing to a buffer, to capture the output.
+type Logger interface {
+	// Info reports progress, it is hidden in quiet mode.
+	Info(format string, v ...interface{})
+	// Verbose reports detail only wanted in verbose mode.
+	Verbose(format string, v ...interface{})
+	// Result reports what a command produced, such as the image it built. It
+	// is shown in quiet mode too.
+	Result(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+	// Writer and ErrorWriter take output that is passed on as is, such as
+	// container logs and pull progress.
+	Writer() io.Writer
+	ErrorWriter() io.Writer
+}
+
+type Options struct {
+	Quiet      bool
+	Verbose    bool
+	Timestamps bool
+	NoColor    bool
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+)
+
+// New returns a Logger writing to out, and warnings and errors to errOut.
+// Colors are only used when the writer is a terminal.
+func New(out, errOut io.Writer, opts Options) Logger {
+	l := &logger{opts: opts, out: out, errOut: errOut}
+	l.colorOut = !opts.NoColor && isTerminal(out)
+	l.colorErr = !opts.NoColor && isTerminal(errOut)
+	if opts.Timestamps {
+		l.out = &timestampWriter{out: out}
+		l.errOut = &timestampWriter{out: errOut}
+	}
+	return l
+}
+
+type logger struct {
+	opts               Options
+	out, errOut        io.Writer
+	colorOut, colorErr bool
+}
+
+func (l *logger) Info(format string, v ...interface{}) {
+	if !l.opts.Quiet {
+		l.print(l.out, "", format, v...)
+	}
+}
+
+func (l *logger) Verbose(format string, v ...interface{}) {
+	if l.opts.Verbose {
+		l.print(l.out, "", format, v...)
+	}
+}
+
+func (l *logger) Result(format string, v ...interface{}) {
+	l.print(l.out, "", format, v...)
+}
+
+func (l *logger) Warn(format string, v ...interface{}) {
+	prefix := "WARNING: "
+	if l.colorErr {
+		prefix = colorYellow + prefix + colorReset
+	}
+	l.print(l.errOut, prefix, format, v...)
+}
+
+func (l *logger) Error(format string, v ...interface{}) {
+	prefix := "ERROR: "
+	if l.colorErr {
+		prefix = colorRed + prefix + colorReset
+	}
+	l.print(l.errOut, prefix, format, v...)
+}
+
+func (l *logger) Writer() io.Writer {
+	if l.opts.Quiet {
+		return ioutil.Discard
+	}
+	return l.out
+}
+
+func (l *logger) ErrorWriter() io.Writer {
+	return l.errOut
+}
+
+func (l *logger) print(w io.Writer, prefix, format string, v ...interface{}) {
+	msg := prefix + fmt.Sprintf(format, v...)
+	if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+		msg += "\n"
+	}
+	io.WriteString(w, msg)
+}
+
+// timestampWriter starts every line with the time it was written, the way
+// log.LstdFlags does.
+type timestampWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	midLine bool
+}
+
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(p)
+	var buf bytes.Buffer
+	for len(p) > 0 {
+		if !w.midLine {
+			buf.WriteString(time.Now().Format("2006/01/02 15:04:05 "))
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			buf.Write(p)
+			w.midLine = true
+			break
+		}
+		buf.Write(p[:i+1])
+		w.midLine = false
+		p = p[i+1:]
+	}
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
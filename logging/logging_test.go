@@ -0,0 +1,85 @@
+package logging_test
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/buildpack/pack/logging"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLogging(t *testing.T) {
+	spec.Run(t, "logging", testLogging, spec.Report(report.Terminal{}))
+}
+
+func testLogging(t *testing.T, when spec.G, it spec.S) {
+	var out, errOut bytes.Buffer
+
+	it.Before(func() {
+		out.Reset()
+		errOut.Reset()
+	})
+
+	logAll := func(logger logging.Logger) {
+		logger.Info("some %s", "info")
+		logger.Verbose("some detail")
+		logger.Result("some result")
+		logger.Warn("some warning")
+		logger.Error("some error")
+		io.WriteString(logger.Writer(), "container output\n")
+		io.WriteString(logger.ErrorWriter(), "container error\n")
+	}
+
+	it("writes info to out and warnings and errors to errOut", func() {
+		logAll(logging.New(&out, &errOut, logging.Options{}))
+
+		if out.String() != "some info\nsome result\ncontainer output\n" {
+			t.Fatalf("unexpected output %q", out.String())
+		}
+		if errOut.String() != "WARNING: some warning\nERROR: some error\ncontainer error\n" {
+			t.Fatalf("unexpected error output %q", errOut.String())
+		}
+	})
+
+	when("quiet", func() {
+		it("only writes results, warnings and errors", func() {
+			logAll(logging.New(&out, &errOut, logging.Options{Quiet: true}))
+
+			if out.String() != "some result\n" {
+				t.Fatalf("expected only the result, got %q", out.String())
+			}
+			if errOut.String() != "WARNING: some warning\nERROR: some error\ncontainer error\n" {
+				t.Fatalf("unexpected error output %q", errOut.String())
+			}
+		})
+	})
+
+	when("verbose", func() {
+		it("also writes detail", func() {
+			logAll(logging.New(&out, &errOut, logging.Options{Verbose: true}))
+
+			if out.String() != "some info\nsome detail\nsome result\ncontainer output\n" {
+				t.Fatalf("unexpected output %q", out.String())
+			}
+		})
+	})
+
+	when("timestamps", func() {
+		it("starts every line with the time", func() {
+			logger := logging.New(&out, &errOut, logging.Options{Timestamps: true})
+			logger.Info("some info")
+			io.WriteString(logger.Writer(), "first ")
+			io.WriteString(logger.Writer(), "line\nsecond line\n")
+
+			if !regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} [^\n]+\n){3}$`).MatchString(out.String()) {
+				t.Fatalf("expected three timestamped lines, got %q", out.String())
+			}
+			if !bytes.Contains(out.Bytes(), []byte(" first line\n")) {
+				t.Fatalf("expected a line to be timestamped once, got %q", out.String())
+			}
+		})
+	})
+}
@@ -33,6 +33,19 @@ func (m *MockFS) EXPECT() *MockFSMockRecorder {
 	return m.recorder
 }
 
+// CreateFilesTarReader mocks base method
+func (m *MockFS) CreateFilesTarReader(arg0, arg1 string, arg2 []string, arg3, arg4 int) (io.Reader, chan error) {
+	ret := m.ctrl.Call(m, "CreateFilesTarReader", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(io.Reader)
+	ret1, _ := ret[1].(chan error)
+	return ret0, ret1
+}
+
+// CreateFilesTarReader indicates an expected call of CreateFilesTarReader
+func (mr *MockFSMockRecorder) CreateFilesTarReader(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFilesTarReader", reflect.TypeOf((*MockFS)(nil).CreateFilesTarReader), arg0, arg1, arg2, arg3, arg4)
+}
+
 // CreateSingleFileTar mocks base method
 func (m *MockFS) CreateSingleFileTar(arg0, arg1 string) (io.Reader, error) {
 	ret := m.ctrl.Call(m, "CreateSingleFileTar", arg0, arg1)
@@ -0,0 +1,80 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/pkg/errors"
+)
+
+// ProcessTypeEnv tells the launcher which process from launch.toml to start,
+// it starts "web" when this is not set.
+const ProcessTypeEnv = "PACK_PROCESS_TYPE"
+
+type launchTOML struct {
+	Processes []struct {
+		Type string `toml:"type"`
+	} `toml:"processes"`
+}
+
+// processTypes lists the process types the buildpacks of group wrote to their
+// launch.toml in the workspace, in the order they were first defined.
+func processTypes(workspaceDir string, group *lifecycle.BuildpackGroup) ([]string, error) {
+	var types []string
+	seen := map[string]bool{}
+	for _, bp := range group.Buildpacks {
+		var launch launchTOML
+		path := filepath.Join(workspaceDir, bp.ID, "launch.toml")
+		if _, err := toml.DecodeFile(path, &launch); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "read launch.toml of buildpack '%s'", bp.ID)
+		}
+		for _, p := range launch.Processes {
+			if !seen[p.Type] {
+				seen[p.Type] = true
+				types = append(types, p.Type)
+			}
+		}
+	}
+	return types, nil
+}
+
+func checkProcessType(workspaceDir string, group *lifecycle.BuildpackGroup, processType string) error {
+	types, err := processTypes(workspaceDir, group)
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t == processType {
+			return nil
+		}
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("process type '%s' not found, the buildpacks did not define any processes", processType)
+	}
+	return fmt.Errorf("process type '%s' not found, available types: %s", processType, strings.Join(types, ", "))
+}
+
+// withProcessType makes processType the process the image starts by default.
+func withProcessType(image v1.Image, processType string) (v1.Image, error) {
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg := configFile.Config.DeepCopy()
+	var env []string
+	for _, kv := range cfg.Env {
+		if !strings.HasPrefix(kv, ProcessTypeEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	cfg.Env = append(env, ProcessTypeEnv+"="+processType)
+	return mutate.Config(image, *cfg)
+}
@@ -0,0 +1,80 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+const ProjectFileName = "project.toml"
+
+// Project is the optional project.toml in the app dir. It holds the settings
+// that would otherwise have to be passed as flags on every build; flags still
+// take precedence over it.
+type Project struct {
+	Image string       `toml:"image"`
+	Build ProjectBuild `toml:"build"`
+}
+
+type ProjectBuild struct {
+	Builder    string            `toml:"builder"`
+	RunImage   string            `toml:"run-image"`
+	Buildpacks []string          `toml:"buildpacks"`
+	Env        map[string]string `toml:"env"`
+	Include    []string          `toml:"include"`
+	Exclude    []string          `toml:"exclude"`
+}
+
+// ReadProject returns the project.toml of dir, or an empty project if there
+// is no such file.
+func ReadProject(dir string) (*Project, error) {
+	var project Project
+	md, err := toml.DecodeFile(filepath.Join(dir, ProjectFileName), &project)
+	if os.IsNotExist(err) {
+		return &project, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "parse %s", ProjectFileName)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf(`unknown key "%s" in %s`, undecoded[0], ProjectFileName)
+	}
+	if err := project.validate(); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (p *Project) validate() error {
+	for _, image := range []struct{ key, value string }{
+		{"image", p.Image},
+		{"build.builder", p.Build.Builder},
+		{"build.run-image", p.Build.RunImage},
+	} {
+		if image.value == "" {
+			continue
+		}
+		if _, err := name.ParseReference(image.value, name.WeakValidation); err != nil {
+			return projectKeyError(image.key, image.value, err.Error())
+		}
+	}
+	for _, bp := range p.Build.Buildpacks {
+		if bp == "" || strings.HasPrefix(bp, "@") || strings.HasSuffix(bp, "@") {
+			return projectKeyError("build.buildpacks", bp, "must be ID or ID@VERSION")
+		}
+	}
+	for k := range p.Build.Env {
+		if !validEnvKey(k) {
+			return projectKeyError("build.env", k, "not a valid variable name")
+		}
+	}
+	return nil
+}
+
+func projectKeyError(key, value, reason string) error {
+	return fmt.Errorf(`invalid "%s" in %s: "%s": %s`, key, ProjectFileName, value, reason)
+}
@@ -0,0 +1,108 @@
+package pack_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpack/pack"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestProject(t *testing.T) {
+	spec.Run(t, "project", testProject, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testProject(t *testing.T, when spec.G, it spec.S) {
+	when("#ReadProject", func() {
+		var appDir string
+
+		it.Before(func() {
+			var err error
+			appDir, err = ioutil.TempDir("", "pack.project.test.")
+			assertNil(t, err)
+		})
+
+		it.After(func() {
+			os.RemoveAll(appDir)
+		})
+
+		writeProject := func(contents string) {
+			assertNil(t, ioutil.WriteFile(filepath.Join(appDir, "project.toml"), []byte(contents), 0644))
+		}
+
+		it("returns an empty project when there is no project.toml", func() {
+			project, err := pack.ReadProject(appDir)
+			assertNil(t, err)
+			assertEq(t, project, &pack.Project{})
+		})
+
+		it("reads all the settings", func() {
+			writeProject(`
+image = "myorg/myapp"
+
+[build]
+builder = "some/builder"
+run-image = "some/run"
+buildpacks = ["some.bp@1.0", "other.bp"]
+include = ["src/"]
+exclude = ["*.log"]
+
+[build.env]
+SOME_KEY = "some-value"
+`)
+			project, err := pack.ReadProject(appDir)
+			assertNil(t, err)
+			assertEq(t, project, &pack.Project{
+				Image: "myorg/myapp",
+				Build: pack.ProjectBuild{
+					Builder:    "some/builder",
+					RunImage:   "some/run",
+					Buildpacks: []string{"some.bp@1.0", "other.bp"},
+					Env:        map[string]string{"SOME_KEY": "some-value"},
+					Include:    []string{"src/"},
+					Exclude:    []string{"*.log"},
+				},
+			})
+		})
+
+		it("returns an error for an unknown key", func() {
+			writeProject(`
+[build]
+buildpack = ["some.bp"]
+`)
+			_, err := pack.ReadProject(appDir)
+			assertError(t, err, `unknown key "build.buildpack" in project.toml`)
+		})
+
+		it("returns an error naming the key of an invalid image", func() {
+			writeProject(`
+[build]
+run-image = "Some/Run"
+`)
+			_, err := pack.ReadProject(appDir)
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), `invalid "build.run-image" in project.toml: "Some/Run"`)
+		})
+
+		it("returns an error naming the key of an invalid buildpack", func() {
+			writeProject(`
+[build]
+buildpacks = ["some.bp@"]
+`)
+			_, err := pack.ReadProject(appDir)
+			assertError(t, err, `invalid "build.buildpacks" in project.toml: "some.bp@": must be ID or ID@VERSION`)
+		})
+
+		it("returns an error for an env var that is not a plain file name", func() {
+			writeProject(`
+[build.env]
+"../../app/x" = "some-value"
+`)
+			_, err := pack.ReadProject(appDir)
+			assertError(t, err, `invalid "build.env" in project.toml: "../../app/x": not a valid variable name`)
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package pack
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// ManagedLabel marks the containers and workspace volumes pack creates, and
+// the images pack run builds, so that pack prune can find the ones left behind.
+const ManagedLabel = "io.buildpacks.pack.managed"
+
+func managedLabels() map[string]string {
+	return map[string]string{ManagedLabel: "true"}
+}
+
+type PruneFactory struct {
+	Log    logging.Logger
+	Docker Docker
+	Config *config.Config
+}
+
+type PruneFlags struct {
+	DryRun bool
+	// AppVolumes also removes the app volumes carrying AppVolumeLabel
+	AppVolumes bool
+}
+
+// PruneResult lists the IDs or names of what was removed, or would be with
+// DryRun, and the disk space in bytes that frees.
+type PruneResult struct {
+	Containers []string
+	Volumes    []string
+	Images     []string
+	Reclaimed  int64
+}
+
+// Prune removes the stopped containers, volumes and dangling images carrying
+// ManagedLabel, and with AppVolumes the app volumes and their manifests.
+// Running containers, and the volumes and images they use, may belong to a
+// build or pack run in progress and are left alone.
+func (f *PruneFactory) Prune(flags PruneFlags) (*PruneResult, error) {
+	ctx := context.Background()
+	du, err := f.Docker.DiskUsage(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list docker resources")
+	}
+	verb := "Removed"
+	if flags.DryRun {
+		verb = "Would remove"
+	}
+
+	result := &PruneResult{}
+	usedVolumes, usedImages := map[string]bool{}, map[string]bool{}
+	for _, c := range du.Containers {
+		if !isManaged(c.Labels) || c.State == "running" || c.State == "restarting" {
+			for _, m := range c.Mounts {
+				usedVolumes[m.Name] = true
+			}
+			usedImages[c.ImageID] = true
+			continue
+		}
+		if !flags.DryRun {
+			if err := f.Docker.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{}); err != nil {
+				return result, errors.Wrapf(err, "remove container %s", shortID(c.ID))
+			}
+		}
+		f.Log.Info("%s container %s", verb, shortID(c.ID))
+		result.Containers = append(result.Containers, c.ID)
+		result.Reclaimed += c.SizeRw
+	}
+
+	for _, v := range du.Volumes {
+		_, appVolume := v.Labels[AppVolumeLabel]
+		if !(isManaged(v.Labels) || (appVolume && flags.AppVolumes)) || usedVolumes[v.Name] {
+			continue
+		}
+		if !flags.DryRun {
+			if err := f.Docker.VolumeRemove(ctx, v.Name, false); err != nil {
+				return result, errors.Wrapf(err, "remove volume %s", v.Name)
+			}
+			// the manifest would only describe a volume that is gone
+			if appVolume {
+				if err := os.Remove(appManifestPath(f.Config, v.Name)); err != nil && !os.IsNotExist(err) {
+					return result, err
+				}
+			}
+		}
+		f.Log.Info("%s volume %s", verb, v.Name)
+		result.Volumes = append(result.Volumes, v.Name)
+		if v.UsageData != nil && v.UsageData.Size > 0 {
+			result.Reclaimed += v.UsageData.Size
+		}
+	}
+
+	for _, i := range du.Images {
+		if !isManaged(i.Labels) || !isDangling(i) || usedImages[i.ID] {
+			continue
+		}
+		if !flags.DryRun {
+			if _, err := f.Docker.ImageRemove(ctx, i.ID, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+				return result, errors.Wrapf(err, "remove image %s", shortID(i.ID))
+			}
+		}
+		f.Log.Info("%s image %s", verb, shortID(i.ID))
+		result.Images = append(result.Images, i.ID)
+		// layers shared with other images, such as the run image, stay
+		if i.SharedSize > 0 {
+			result.Reclaimed += i.Size - i.SharedSize
+		} else {
+			result.Reclaimed += i.Size
+		}
+	}
+	return result, nil
+}
+
+func isManaged(labels map[string]string) bool {
+	_, ok := labels[ManagedLabel]
+	return ok
+}
+
+// isDangling is true for an image no longer tagged, such as a pack run image
+// replaced by a later run.
+func isDangling(i *types.ImageSummary) bool {
+	for _, tag := range i.RepoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,120 @@
+package pack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpack/pack"
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
+	"github.com/buildpack/pack/mocks"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/golang/mock/gomock"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestPrune(t *testing.T) {
+	spec.Run(t, "prune", testPrune, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testPrune(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockController *gomock.Controller
+		mockDocker     *mocks.MockDocker
+		factory        pack.PruneFactory
+		buf            bytes.Buffer
+		configDir      string
+	)
+	managed := map[string]string{pack.ManagedLabel: "true"}
+
+	it.Before(func() {
+		var err error
+		configDir, err = ioutil.TempDir("", "pack.prune.config.")
+		assertNil(t, err)
+		cfg, err := config.New(configDir)
+		assertNil(t, err)
+		mockController = gomock.NewController(t)
+		mockDocker = mocks.NewMockDocker(mockController)
+		factory = pack.PruneFactory{
+			Log:    logging.New(&buf, &buf, logging.Options{}),
+			Docker: mockDocker,
+			Config: cfg,
+		}
+
+		mockDocker.EXPECT().DiskUsage(gomock.Any()).Return(dockertypes.DiskUsage{
+			Containers: []*dockertypes.Container{
+				{ID: "exited-container", State: "exited", Labels: managed, SizeRw: 10, Mounts: []dockertypes.MountPoint{{Name: "leaked-workspace"}}},
+				{ID: "running-container", State: "running", Labels: managed, ImageID: "sha256:running-image", Mounts: []dockertypes.MountPoint{{Name: "busy-workspace"}}},
+				{ID: "other-container", State: "exited", Mounts: []dockertypes.MountPoint{{Name: "other-workspace"}}},
+			},
+			Volumes: []*dockertypes.Volume{
+				{Name: "leaked-workspace", Labels: managed, UsageData: &dockertypes.VolumeUsageData{Size: 100, RefCount: 1}},
+				{Name: "busy-workspace", Labels: managed, UsageData: &dockertypes.VolumeUsageData{Size: 200, RefCount: 1}},
+				{Name: "other-workspace", Labels: managed, UsageData: &dockertypes.VolumeUsageData{Size: 300, RefCount: 1}},
+				{Name: "pack-cache-some-app", UsageData: &dockertypes.VolumeUsageData{Size: 400}},
+				{Name: "pack-app-some-app", Labels: map[string]string{pack.AppVolumeLabel: "true"}, UsageData: &dockertypes.VolumeUsageData{Size: 500}},
+			},
+			Images: []*dockertypes.ImageSummary{
+				{ID: "sha256:old-run-image", Labels: managed, RepoTags: []string{"<none>:<none>"}, Size: 5000, SharedSize: 4000},
+				{ID: "sha256:current-run-image", Labels: managed, RepoTags: []string{"pack.local/run/abc:latest"}, Size: 5000, SharedSize: 4000},
+				{ID: "sha256:running-image", Labels: managed, Size: 5000, SharedSize: 4000},
+				{ID: "sha256:other-image", Size: 5000},
+			},
+		}, nil)
+	})
+
+	it.After(func() {
+		mockController.Finish()
+		os.RemoveAll(configDir)
+	})
+
+	it("removes stopped managed containers and the managed volumes and dangling images nothing else uses", func() {
+		mockDocker.EXPECT().ContainerRemove(gomock.Any(), "exited-container", dockertypes.ContainerRemoveOptions{})
+		mockDocker.EXPECT().VolumeRemove(gomock.Any(), "leaked-workspace", false)
+		mockDocker.EXPECT().ImageRemove(gomock.Any(), "sha256:old-run-image", dockertypes.ImageRemoveOptions{PruneChildren: true})
+
+		result, err := factory.Prune(pack.PruneFlags{})
+		assertNil(t, err)
+		assertEq(t, result, &pack.PruneResult{
+			Containers: []string{"exited-container"},
+			Volumes:    []string{"leaked-workspace"},
+			Images:     []string{"sha256:old-run-image"},
+			Reclaimed:  10 + 100 + 1000,
+		})
+		assertContains(t, buf.String(), "Removed volume leaked-workspace")
+	})
+
+	when("--app-volumes", func() {
+		it("also removes the app volumes and their manifests", func() {
+			manifest := filepath.Join(configDir, "workspaces", "pack-app-some-app.json")
+			assertNil(t, os.MkdirAll(filepath.Dir(manifest), 0777))
+			assertNil(t, ioutil.WriteFile(manifest, []byte("{}"), 0666))
+			mockDocker.EXPECT().ContainerRemove(gomock.Any(), "exited-container", dockertypes.ContainerRemoveOptions{})
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "leaked-workspace", false)
+			mockDocker.EXPECT().VolumeRemove(gomock.Any(), "pack-app-some-app", false)
+			mockDocker.EXPECT().ImageRemove(gomock.Any(), "sha256:old-run-image", dockertypes.ImageRemoveOptions{PruneChildren: true})
+
+			result, err := factory.Prune(pack.PruneFlags{AppVolumes: true})
+			assertNil(t, err)
+			assertEq(t, result.Volumes, []string{"leaked-workspace", "pack-app-some-app"})
+			assertEq(t, result.Reclaimed, int64(1610))
+			if _, err := os.Stat(manifest); !os.IsNotExist(err) {
+				t.Fatalf("expected the app volume manifest to be removed, got: %v", err)
+			}
+		})
+	})
+
+	when("--dry-run", func() {
+		it("reports what would be removed without removing it", func() {
+			result, err := factory.Prune(pack.PruneFlags{DryRun: true})
+			assertNil(t, err)
+			assertEq(t, result.Volumes, []string{"leaked-workspace"})
+			assertEq(t, result.Reclaimed, int64(1110))
+			assertContains(t, buf.String(), "Would remove image old-run-imag")
+		})
+	})
+}
@@ -0,0 +1,47 @@
+package pack
+
+import (
+	"context"
+	"fmt"
+
+	dockercli "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// PullPolicy decides when images are pulled before use. The zero value
+// behaves like PullAlways.
+type PullPolicy string
+
+const (
+	PullAlways       PullPolicy = "always"
+	PullIfNotPresent PullPolicy = "if-not-present"
+	PullNever        PullPolicy = "never"
+)
+
+func ParsePullPolicy(policy string) (PullPolicy, error) {
+	switch p := PullPolicy(policy); p {
+	case PullAlways, PullIfNotPresent, PullNever:
+		return p, nil
+	case "":
+		return PullAlways, nil
+	}
+	return "", fmt.Errorf(`invalid pull policy "%s": must be one of "always", "if-not-present" or "never"`, policy)
+}
+
+// shouldPull reports whether ref has to be pulled under the policy, checking
+// the daemon for the image when the policy is PullIfNotPresent.
+func shouldPull(cli Docker, policy PullPolicy, ref string) (bool, error) {
+	switch policy {
+	case PullNever:
+		return false, nil
+	case PullIfNotPresent:
+		_, _, err := cli.ImageInspectWithRaw(context.Background(), ref)
+		if dockercli.IsErrNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			return false, errors.Wrapf(err, "inspect image '%s'", ref)
+		}
+		return false, nil
+	}
+	return true, nil
+}
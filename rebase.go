@@ -4,13 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 
 	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/image"
+	"github.com/buildpack/pack/logging"
 	dockercli "github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/pkg/errors"
 )
@@ -29,25 +29,36 @@ type WritableStore interface {
 }
 
 type RebaseFactory struct {
-	Log    *log.Logger
+	Log    logging.Logger
 	Docker Docker
 	Config *config.Config
 	Images Images
 }
 
 type RebaseFlags struct {
-	RepoName string
-	Publish  bool
-	NoPull   bool
+	RepoName   string
+	Publish    bool
+	PullPolicy PullPolicy
+}
+
+func (f *RebaseFactory) pull(flags RebaseFlags, kind, ref string) error {
+	if flags.Publish {
+		return nil
+	}
+	pull, err := shouldPull(f.Docker, flags.PullPolicy, ref)
+	if err != nil || !pull {
+		return err
+	}
+	f.Log.Info("Pulling %s %s", kind, ref)
+	if err := f.Docker.PullImage(ref); err != nil {
+		return fmt.Errorf(`failed to pull stack build image "%s": %s`, ref, err)
+	}
+	return nil
 }
 
 func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig, error) {
-	if !flags.NoPull && !flags.Publish {
-		f.Log.Println("Pulling image", flags.RepoName)
-		err := f.Docker.PullImage(flags.RepoName)
-		if err != nil {
-			return RebaseConfig{}, fmt.Errorf(`failed to pull stack build image "%s": %s`, flags.RepoName, err)
-		}
+	if err := f.pull(flags, "image", flags.RepoName); err != nil {
+		return RebaseConfig{}, err
 	}
 	stackID, err := f.imageLabel(flags.RepoName, "io.buildpacks.stack.id", !flags.Publish)
 	if err != nil {
@@ -58,13 +69,8 @@ func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig,
 	if err != nil {
 		return RebaseConfig{}, err
 	}
-	if !flags.NoPull && !flags.Publish {
-		f.Log.Println("Pulling base image", baseImageName)
-		err := f.Docker.PullImage(baseImageName)
-		if err != nil {
-
-			return RebaseConfig{}, fmt.Errorf(`failed to pull stack build image "%s": %s`, baseImageName, err)
-		}
+	if err := f.pull(flags, "base image", baseImageName); err != nil {
+		return RebaseConfig{}, err
 	}
 
 	repoStore, err := f.Images.RepoStore(flags.RepoName, !flags.Publish)
@@ -72,7 +78,7 @@ func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig,
 		return RebaseConfig{}, fmt.Errorf(`failed to create repository store for image "%s": %s`, flags.RepoName, err)
 	}
 
-	f.Log.Println("Reading image", flags.RepoName)
+	f.Log.Info("Reading image %s", flags.RepoName)
 	repoImage, err := f.Images.ReadImage(flags.RepoName, !flags.Publish)
 	if err != nil {
 		return RebaseConfig{}, fmt.Errorf(`failed to read image "%s": %s`, flags.RepoName, err)
@@ -81,7 +87,7 @@ func (f *RebaseFactory) RebaseConfigFromFlags(flags RebaseFlags) (RebaseConfig,
 	if err != nil {
 		return RebaseConfig{}, fmt.Errorf(`failed to read old base image "%s": %s`, baseImageName, err)
 	}
-	f.Log.Println("Reading new base image", baseImageName)
+	f.Log.Info("Reading new base image %s", baseImageName)
 	newBase, err := f.Images.ReadImage(baseImageName, !flags.Publish)
 	if err != nil {
 		return RebaseConfig{}, fmt.Errorf(`failed to read new base image "%s": %s`, baseImageName, err)
@@ -118,7 +124,7 @@ func (f *RebaseFactory) Rebase(cfg RebaseConfig) error {
 		return err
 	}
 
-	f.Log.Printf("Successfully replaced %s with %s\n", cfg.RepoName, h)
+	f.Log.Result("Successfully replaced %s with %s", cfg.RepoName, h)
 	return nil
 }
 
@@ -156,11 +162,10 @@ func (f *RebaseFactory) imageLabel(repoName, key string, useDaemon bool) (string
 		}
 		config, err := origImage.ConfigFile()
 		if err != nil {
-			if remoteErr, ok := err.(*remote.Error); ok && len(remoteErr.Errors) > 0 {
-				switch remoteErr.Errors[0].Code {
-				case remote.UnauthorizedErrorCode, remote.ManifestUnknownErrorCode:
-					return "", nil
-				}
+			if err := image.RegistryError(repoName, err); err == nil {
+				return "", nil
+			} else if _, ok := err.(*image.AuthError); ok {
+				return "", err
 			}
 			return "", errors.Wrapf(err, "access manifest: %s", repoName)
 		}
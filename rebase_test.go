@@ -4,11 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"testing"
 
 	"github.com/buildpack/pack"
 	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/mocks"
 	dockertypes "github.com/docker/docker/api/types"
 	dockercontainer "github.com/docker/docker/api/types/container"
@@ -46,7 +46,7 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 
 			factory = pack.RebaseFactory{
 				Docker: mockDocker,
-				Log:    log.New(ioutil.Discard, "", log.LstdFlags),
+				Log:    logging.New(ioutil.Discard, ioutil.Discard, logging.Options{}),
 				Config: &config.Config{
 					DefaultStackID: "some.default.stack",
 					Stacks: []config.Stack{
@@ -97,15 +97,15 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 					}, nil, nil).AnyTimes()
 				})
 
-				when("no-pull is false", func() {
+				when("pull policy is always", func() {
 					it("XXXX", func() {
 						mockDocker.EXPECT().PullImage("default/run")
 						mockDocker.EXPECT().PullImage("myorg/myrepo")
 
 						cfg, err := factory.RebaseConfigFromFlags(pack.RebaseFlags{
-							RepoName: "myorg/myrepo",
-							Publish:  false,
-							NoPull:   false,
+							RepoName:   "myorg/myrepo",
+							Publish:    false,
+							PullPolicy: pack.PullAlways,
 						})
 						assertNil(t, err)
 
@@ -118,12 +118,12 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 					})
 				})
 
-				when("no-pull is true", func() {
+				when("pull policy is never", func() {
 					it("XXXX", func() {
 						cfg, err := factory.RebaseConfigFromFlags(pack.RebaseFlags{
-							RepoName: "myorg/myrepo",
-							Publish:  false,
-							NoPull:   true,
+							RepoName:   "myorg/myrepo",
+							Publish:    false,
+							PullPolicy: pack.PullNever,
 						})
 						assertNil(t, err)
 
@@ -135,6 +135,23 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 						assertSameInstance(t, cfg.NewBase, mockBaseImage)
 					})
 				})
+
+				when("pull policy is if-not-present", func() {
+					it("only pulls images missing from the daemon", func() {
+						mockDocker.EXPECT().ImageInspectWithRaw(gomock.Any(), "default/run").Return(dockertypes.ImageInspect{}, nil, notFoundError{})
+						mockDocker.EXPECT().PullImage("default/run")
+
+						cfg, err := factory.RebaseConfigFromFlags(pack.RebaseFlags{
+							RepoName:   "myorg/myrepo",
+							Publish:    false,
+							PullPolicy: pack.PullIfNotPresent,
+						})
+						assertNil(t, err)
+
+						assertSameInstance(t, cfg.RepoImage, mockRepoImage)
+						assertSameInstance(t, cfg.NewBase, mockBaseImage)
+					})
+				})
 			})
 
 			when("publish is true", func() {
@@ -153,12 +170,12 @@ func testRebase(t *testing.T, when spec.G, it spec.S) {
 					}, nil).AnyTimes()
 				})
 
-				when("no-pull is anything", func() {
+				when("pull policy is anything", func() {
 					it("XXXX", func() {
 						cfg, err := factory.RebaseConfigFromFlags(pack.RebaseFlags{
-							RepoName: "myorg/myrepo",
-							Publish:  true,
-							NoPull:   false,
+							RepoName:   "myorg/myrepo",
+							Publish:    true,
+							PullPolicy: pack.PullAlways,
 						})
 						assertNil(t, err)
 
@@ -284,3 +301,9 @@ func assertLayers(t *testing.T, actual v1.Image, expected []v1.Layer) {
 		assertSameInstance(t, actualLayers[i], expected[i])
 	}
 }
+
+// notFoundError is recognized by dockercli.IsErrNotFound
+type notFoundError struct{}
+
+func (notFoundError) Error() string  { return "not found" }
+func (notFoundError) NotFound() bool { return true }
@@ -0,0 +1,134 @@
+package pack
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/lifecycle"
+	"github.com/pkg/errors"
+)
+
+// BuildReport records what went into a build, for provenance. It is written
+// to BuildConfig.ReportPath once the build succeeds.
+type BuildReport struct {
+	StackID    string            `toml:"stack-id" json:"stack-id"`
+	Builder    ReportImage       `toml:"builder" json:"builder"`
+	RunImage   ReportImage       `toml:"run-image" json:"run-image"`
+	Image      ReportImage       `toml:"image" json:"image"`
+	Buildpacks []ReportBuildpack `toml:"buildpacks" json:"buildpacks"`
+}
+
+type ReportImage struct {
+	Name   string   `toml:"name" json:"name"`
+	Digest string   `toml:"digest,omitempty" json:"digest,omitempty"`
+	ID     string   `toml:"id,omitempty" json:"id,omitempty"`
+	Tags   []string `toml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+type ReportBuildpack struct {
+	ID      string        `toml:"id" json:"id"`
+	Version string        `toml:"version" json:"version"`
+	Layers  []ReportLayer `toml:"layers" json:"layers"`
+}
+
+type ReportLayer struct {
+	Name   string `toml:"name" json:"name"`
+	Reused bool   `toml:"reused" json:"reused"`
+}
+
+func (r *BuildReport) setGroup(group *lifecycle.BuildpackGroup) {
+	if r == nil {
+		return
+	}
+	r.Buildpacks = nil
+	for _, bp := range group.Buildpacks {
+		r.Buildpacks = append(r.Buildpacks, ReportBuildpack{ID: bp.ID, Version: bp.Version, Layers: []ReportLayer{}})
+	}
+}
+
+func (r *BuildReport) addLayer(buildpack, layer string, reused bool) {
+	if r == nil {
+		return
+	}
+	for i := range r.Buildpacks {
+		if r.Buildpacks[i].ID == buildpack {
+			r.Buildpacks[i].Layers = append(r.Buildpacks[i].Layers, ReportLayer{Name: layer, Reused: reused})
+			return
+		}
+	}
+	r.Buildpacks = append(r.Buildpacks, ReportBuildpack{ID: buildpack, Layers: []ReportLayer{{Name: layer, Reused: reused}}})
+}
+
+// Write writes the report as JSON if path ends in .json, and as TOML otherwise.
+func (r *BuildReport) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "create report")
+	}
+	defer fh.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(fh)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	} else {
+		err = toml.NewEncoder(fh).Encode(r)
+	}
+	return errors.Wrapf(err, "write report '%s'", path)
+}
+
+// reportImages fills in the builder, run image and stack of the report. The
+// builder is always in the daemon; the run image only when not publishing.
+func (b *BuildConfig) reportImages(ctx context.Context) error {
+	if b.report == nil {
+		return nil
+	}
+	stackID, err := b.imageLabel(b.Builder, "io.buildpacks.stack.id", true)
+	if err != nil {
+		return err
+	}
+	b.report.StackID = stackID
+
+	if b.report.Builder, err = b.daemonReportImage(ctx, b.Builder); err != nil {
+		return err
+	}
+	if !b.Publish {
+		b.report.RunImage, err = b.daemonReportImage(ctx, b.RunImage)
+		return err
+	}
+
+	b.report.RunImage = ReportImage{Name: b.RunImage}
+	runImage, err := b.Images.ReadImage(b.RunImage, false)
+	if err != nil {
+		return errors.Wrapf(err, "read run image '%s'", b.RunImage)
+	}
+	if runImage == nil {
+		return nil
+	}
+	digest, err := runImage.Digest()
+	if err != nil {
+		return errors.Wrapf(err, "read run image digest '%s'", b.RunImage)
+	}
+	b.report.RunImage.Digest = digest.String()
+	return nil
+}
+
+func (b *BuildConfig) daemonReportImage(ctx context.Context, name string) (ReportImage, error) {
+	i, _, err := b.Cli.ImageInspectWithRaw(ctx, name)
+	if err != nil {
+		return ReportImage{}, errors.Wrapf(err, "inspect image '%s'", name)
+	}
+	image := ReportImage{Name: name, ID: i.ID}
+	if len(i.RepoDigests) > 0 {
+		// images that were never pulled or pushed have no repo digest
+		image.Digest = i.RepoDigests[0][strings.LastIndex(i.RepoDigests[0], "@")+1:]
+	}
+	return image, nil
+}
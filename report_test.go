@@ -0,0 +1,76 @@
+package pack_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpack/pack"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestReport(t *testing.T) {
+	spec.Run(t, "report", testReport, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testReport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir      string
+		buildReport *pack.BuildReport
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "pack.report.test.")
+		assertNil(t, err)
+
+		buildReport = &pack.BuildReport{
+			StackID:  "some.stack.id",
+			Builder:  pack.ReportImage{Name: "some/builder", Digest: "sha256:builder", ID: "sha256:builder-id"},
+			RunImage: pack.ReportImage{Name: "some/run", Digest: "sha256:run"},
+			Image:    pack.ReportImage{Name: "some/app", ID: "sha256:app-id"},
+			Buildpacks: []pack.ReportBuildpack{
+				{
+					ID:      "some.bp",
+					Version: "1.2.3",
+					Layers: []pack.ReportLayer{
+						{Name: "deps", Reused: true},
+						{Name: "app", Reused: false},
+					},
+				},
+			},
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#Write", func() {
+		it("writes TOML by default", func() {
+			path := filepath.Join(tmpDir, "reports", "report.toml")
+			assertNil(t, buildReport.Write(path))
+
+			var actual pack.BuildReport
+			_, err := toml.DecodeFile(path, &actual)
+			assertNil(t, err)
+			assertEq(t, &actual, buildReport)
+		})
+
+		it("writes JSON when the file ends in .json", func() {
+			path := filepath.Join(tmpDir, "report.json")
+			assertNil(t, buildReport.Write(path))
+
+			txt, err := ioutil.ReadFile(path)
+			assertNil(t, err)
+			var actual pack.BuildReport
+			assertNil(t, json.Unmarshal(txt, &actual))
+			assertEq(t, &actual, buildReport)
+			assertContains(t, string(txt), `"run-image": {`)
+		})
+	})
+}
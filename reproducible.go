@@ -0,0 +1,134 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1"
+)
+
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// normalizedDate is used for file and image dates in reproducible builds when
+// SOURCE_DATE_EPOCH is not set. Some tools misbehave on the zero unix time.
+var normalizedDate = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// sourceDate returns the date reproducible builds stamp on files and images,
+// taken from SOURCE_DATE_EPOCH in the build env or else pack's own environment.
+func sourceDate(env map[string]string) (time.Time, error) {
+	epoch, ok := env[sourceDateEpochEnv]
+	if !ok {
+		epoch, ok = os.LookupEnv(sourceDateEpochEnv)
+	}
+	if !ok || epoch == "" {
+		return normalizedDate, nil
+	}
+	secs, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil || secs < 0 {
+		return time.Time{}, fmt.Errorf("invalid %s '%s': must be a number of seconds since the unix epoch", sourceDateEpochEnv, epoch)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// normalizeModTimes sets the mtime of everything under dir to t. Files lose
+// their original mtimes when the workspace is copied out of its volume, so
+// this is what makes the exported layers identical between builds.
+func normalizeModTimes(dir string, t time.Time) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			// os.Chtimes follows links, the target gets its own visit
+			return nil
+		}
+		return os.Chtimes(path, t, t)
+	})
+}
+
+// createdAtImage sets the created date of an image and all of its history,
+// which the exporter would otherwise take from the time of the build.
+type createdAtImage struct {
+	v1.Image
+	created time.Time
+}
+
+func withCreatedAt(image v1.Image, created time.Time) v1.Image {
+	return &createdAtImage{Image: image, created: created}
+}
+
+func (i *createdAtImage) ConfigFile() (*v1.ConfigFile, error) {
+	raw, err := i.Image.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	// parse a fresh copy rather than modifying the one the wrapped image holds on to
+	cfg, err := v1.ParseConfigFile(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Created = v1.Time{Time: i.created}
+	for n := range cfg.History {
+		cfg.History[n].Created = v1.Time{Time: i.created}
+	}
+	return cfg, nil
+}
+
+func (i *createdAtImage) RawConfigFile() ([]byte, error) {
+	cfg, err := i.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cfg)
+}
+
+func (i *createdAtImage) ConfigName() (v1.Hash, error) {
+	raw, err := i.RawConfigFile()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(raw))
+	return h, err
+}
+
+func (i *createdAtImage) Manifest() (*v1.Manifest, error) {
+	rawManifest, err := i.Image.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	m, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, err
+	}
+	rawConfig, err := i.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	m.Config.Digest, m.Config.Size, err = v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (i *createdAtImage) RawManifest() ([]byte, error) {
+	m, err := i.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (i *createdAtImage) Digest() (v1.Hash, error) {
+	raw, err := i.RawManifest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	h, _, err := v1.SHA256(bytes.NewReader(raw))
+	return h, err
+}
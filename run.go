@@ -5,22 +5,30 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
-	"log"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/buildpack/pack/logging"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/go-connections/nat"
 	"github.com/pkg/errors"
 )
 
+// runRepoPrefix names the images pack run builds, one per app dir
+const runRepoPrefix = "pack.local/run/"
+
 type RunFlags struct {
-	AppDir   string
-	Builder  string
-	RunImage string
-	Port     string
+	AppDir     string
+	Builder    string
+	RunImage   string
+	Port       string
+	PullPolicy PullPolicy
+	// Process is the process type to run instead of the image default, it
+	// becomes the default process of the image pack run builds
+	Process string
 }
 
 type RunConfig struct {
@@ -31,17 +39,19 @@ type RunConfig struct {
 	Cli      Docker
 	Stdout   io.Writer
 	Stderr   io.Writer
-	Log      *log.Logger
+	Log      logging.Logger
 }
 
 func (bf *BuildFactory) RunConfigFromFlags(f *RunFlags) (*RunConfig, error) {
 	bc, err := bf.BuildConfigFromFlags(&BuildFlags{
-		AppDir:   f.AppDir,
-		Builder:  f.Builder,
-		RunImage: f.RunImage,
-		RepoName: f.repoName(),
-		Publish:  false,
-		NoPull:   false,
+		AppDir:              f.AppDir,
+		Builder:             f.Builder,
+		RunImage:            f.RunImage,
+		RepoName:            f.repoName(),
+		Publish:             false,
+		PullPolicy:          f.PullPolicy,
+		DefaultProcess:      f.Process,
+		PersistentWorkspace: true,
 	})
 	if err != nil {
 		return nil, err
@@ -61,7 +71,7 @@ func (bf *BuildFactory) RunConfigFromFlags(f *RunFlags) (*RunConfig, error) {
 }
 
 func Run(appDir, buildImage, runImage, port string, makeStopCh func() <-chan struct{}) error {
-	bf, err := DefaultBuildFactory()
+	bf, err := DefaultBuildFactory(logging.New(os.Stdout, os.Stderr, logging.Options{}))
 	if err != nil {
 		return err
 	}
@@ -85,7 +95,7 @@ func (r *RunConfig) Run(makeStopCh func() <-chan struct{}) error {
 		return err
 	}
 
-	fmt.Println("*** RUNNING:")
+	r.Log.Info("*** RUNNING:")
 	if r.Port == "" {
 		r.Port, err = r.exposedPorts(ctx, r.RepoName)
 		if err != nil {
@@ -101,6 +111,7 @@ func (r *RunConfig) Run(makeStopCh func() <-chan struct{}) error {
 		AttachStdout: true,
 		AttachStderr: true,
 		ExposedPorts: exposedPorts,
+		Labels:       managedLabels(),
 	}, &container.HostConfig{
 		AutoRemove:   true,
 		PortBindings: portBindings,
@@ -128,7 +139,7 @@ func (r *RunFlags) repoName() string {
 	// we can ignore errors here because they will be caught later by the Build command
 	h := md5.New()
 	io.WriteString(h, dir)
-	return fmt.Sprintf("pack.local/run/%x", h.Sum(nil))
+	return fmt.Sprintf("%s%x", runRepoPrefix, h.Sum(nil))
 }
 
 func (r *RunConfig) exposedPorts(ctx context.Context, imageID string) (string, error) {
@@ -157,7 +168,7 @@ func parsePorts(port string) (nat.PortSet, nat.PortMap, error) {
 	return nat.ParsePortSpecs(ports)
 }
 
-func logContainerListening(log *log.Logger, portBindings nat.PortMap) {
+func logContainerListening(log logging.Logger, portBindings nat.PortMap) {
 	// TODO handle case with multiple ports, for now when there is more than
 	// one port we assume you know what you're doing and don't need guidance
 	if len(portBindings) == 1 {
@@ -170,7 +181,7 @@ func logContainerListening(log *log.Logger, portBindings nat.PortMap) {
 					host = "localhost"
 				}
 				// TODO the service may not be http based
-				log.Printf("Starting container listening at http://%s:%s/\n", host, port)
+				log.Info("Starting container listening at http://%s:%s/", host, port)
 			}
 		}
 	}
@@ -6,7 +6,6 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"path/filepath"
 	"reflect"
@@ -16,6 +15,7 @@ import (
 	"github.com/buildpack/pack"
 	"github.com/buildpack/pack/config"
 	"github.com/buildpack/pack/fs"
+	"github.com/buildpack/pack/logging"
 	"github.com/buildpack/pack/mocks"
 	"github.com/docker/docker/api/types"
 	dockertypes "github.com/docker/docker/api/types"
@@ -60,7 +60,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 				Cli:    mockDocker,
 				Stdout: &buf,
 				Stderr: &buf,
-				Log:    log.New(&buf, "", log.LstdFlags|log.Lshortfile),
+				Log:    logging.New(&buf, &buf, logging.Options{}),
 				FS:     &fs.FS{},
 				Images: mockImages,
 				Config: &config.Config{
@@ -94,6 +94,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 				Builder:  "some/builder",
 				RunImage: "some/run",
 				Port:     "1370",
+				Process:  "worker",
 			})
 			assertNil(t, err)
 
@@ -106,6 +107,8 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 
 			build, ok := run.Build.(*pack.BuildConfig)
 			assertEq(t, ok, true)
+			assertEq(t, build.DefaultProcess, "worker")
+			assertEq(t, build.AppVolume, pack.AppVolumeName(absAppDir))
 			for _, field := range []string{
 				"RepoName",
 				"Cli",
@@ -142,7 +145,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 				RepoName: "pack.local/run/346ffb210a2c6d138c8d058d6d4025a0",
 				Port:     "1370",
 				Cli:      mockDocker,
-				Log:      log.New(&buf, "", log.LstdFlags|log.Lshortfile),
+				Log:      logging.New(&buf, &buf, logging.Options{}),
 				Stdout:   &buf,
 				Stderr:   &buf,
 			}
@@ -160,6 +163,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 				AttachStdout: true,
 				AttachStderr: true,
 				ExposedPorts: exposedPorts,
+				Labels:       map[string]string{pack.ManagedLabel: "true"},
 			}, &container.HostConfig{
 				AutoRemove:   true,
 				PortBindings: portBindings,
@@ -233,6 +237,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 					AttachStdout: true,
 					AttachStderr: true,
 					ExposedPorts: exposedPorts,
+					Labels:       map[string]string{pack.ManagedLabel: "true"},
 				}, &container.HostConfig{
 					AutoRemove:   true,
 					PortBindings: portBindings,
@@ -257,6 +262,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 					AttachStdout: true,
 					AttachStderr: true,
 					ExposedPorts: exposedPorts,
+					Labels:       map[string]string{pack.ManagedLabel: "true"},
 				}, &container.HostConfig{
 					AutoRemove:   true,
 					PortBindings: portBindings,
@@ -280,6 +286,7 @@ func testRun(t *testing.T, when spec.G, it spec.S) {
 					AttachStdout: true,
 					AttachStderr: true,
 					ExposedPorts: exposedPorts,
+					Labels:       map[string]string{pack.ManagedLabel: "true"},
 				}, &container.HostConfig{
 					AutoRemove:   true,
 					PortBindings: portBindings,
@@ -0,0 +1,337 @@
+package pack
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type sourceKind int
+
+const (
+	sourceDir sourceKind = iota
+	sourceGit
+	sourceArchive
+)
+
+// appSource is where the app to build comes from: a local directory, a git
+// repository (url[#ref][:subdir]) or a .tgz, .tar.gz, .tar or .zip archive,
+// either local or over http(s).
+type appSource struct {
+	kind     sourceKind
+	location string
+	ref      string
+	subdir   string
+}
+
+func parseSource(path string) (*appSource, error) {
+	if isGitSource(path) {
+		s := &appSource{kind: sourceGit, location: path}
+		if i := strings.LastIndex(path, "#"); i >= 0 {
+			s.location = path[:i]
+			s.ref = path[i+1:]
+			if j := strings.Index(s.ref, ":"); j >= 0 {
+				s.subdir = s.ref[j+1:]
+				s.ref = s.ref[:j]
+			}
+		}
+		s.location = strings.TrimPrefix(s.location, "git+")
+		if !isRemote(s.location) && !strings.HasPrefix(s.location, "git@") {
+			abs, err := filepath.Abs(s.location)
+			if err != nil {
+				return nil, err
+			}
+			s.location = abs
+		}
+		return s, nil
+	}
+	if archiveExt(path) != "" {
+		if isRemote(path) {
+			return &appSource{kind: sourceArchive, location: path}, nil
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		return &appSource{kind: sourceArchive, location: abs}, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &appSource{kind: sourceDir, location: abs}, nil
+}
+
+// Identity names the source independently of where it is fetched to, so that
+// repeat builds of the same source share a cache volume.
+func (s *appSource) Identity() string {
+	if s.kind != sourceGit {
+		return s.location
+	}
+	id := s.location
+	if s.ref != "" || s.subdir != "" {
+		id += "#" + s.ref
+	}
+	if s.subdir != "" {
+		id += ":" + s.subdir
+	}
+	return id
+}
+
+// Fetch returns a local directory containing the app, and a function that
+// removes anything that was fetched.
+func (s *appSource) Fetch(fs FS) (string, func(), error) {
+	if s.kind == sourceDir {
+		return s.location, func() {}, nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "pack.source.")
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	var dir string
+	if s.kind == sourceGit {
+		dir, err = s.fetchGit(tmpDir)
+	} else {
+		dir, err = s.fetchArchive(fs, tmpDir)
+	}
+	if err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	return dir, cleanup, nil
+}
+
+func (s *appSource) fetchGit(tmpDir string) (string, error) {
+	repoDir := filepath.Join(tmpDir, "repo")
+	if out, err := exec.Command("git", "clone", "--quiet", s.location, repoDir).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git clone '%s': %s: %s", s.location, err, strings.TrimSpace(string(out)))
+	}
+	if s.ref != "" {
+		if out, err := exec.Command("git", "-C", repoDir, "checkout", "--quiet", s.ref).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git checkout '%s': %s: %s", s.ref, err, strings.TrimSpace(string(out)))
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(repoDir, ".git")); err != nil {
+		return "", err
+	}
+
+	repoDir, err := filepath.EvalSymlinks(repoDir)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(repoDir, filepath.FromSlash(s.subdir))
+	if !isSubpath(repoDir, dir) {
+		return "", fmt.Errorf("invalid subdirectory '%s'", s.subdir)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", fmt.Errorf("subdirectory '%s' not found in '%s'", s.subdir, s.location)
+	}
+	// the subdirectory itself may be a symlink
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", err
+	}
+	if !isSubpath(repoDir, dir) {
+		return "", fmt.Errorf("invalid subdirectory '%s'", s.subdir)
+	}
+	if err := checkSymlinks(dir); err != nil {
+		return "", errors.Wrapf(err, "clone '%s'", s.location)
+	}
+	return dir, nil
+}
+
+func (s *appSource) fetchArchive(fs FS, tmpDir string) (string, error) {
+	archivePath := s.location
+	if isRemote(s.location) {
+		archivePath = filepath.Join(tmpDir, "archive"+archiveExt(s.location))
+		if err := downloadToFile(s.location, archivePath); err != nil {
+			return "", errors.Wrapf(err, "failed to download from %q", s.location)
+		}
+	}
+
+	dir := filepath.Join(tmpDir, "app")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := extractArchive(fs, archivePath, dir); err != nil {
+		return "", errors.Wrapf(err, "extract '%s'", s.location)
+	}
+	if err := checkSymlinks(dir); err != nil {
+		return "", errors.Wrapf(err, "extract '%s'", s.location)
+	}
+
+	// archives of a whole directory (e.g. from GitHub) have a single top level dir
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return dir, nil
+}
+
+func extractArchive(fs FS, archivePath, dest string) error {
+	if archiveExt(archivePath) == ".zip" {
+		return extractZip(archivePath, dest)
+	}
+
+	fh, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var r io.Reader = fh
+	if archiveExt(archivePath) != ".tar" {
+		gzr, err := gzip.NewReader(fh)
+		if err != nil {
+			return errors.Wrap(err, "could not unzip")
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	return fs.Untar(r, dest)
+}
+
+// checkSymlinks fails on symlinks in dir that lead outside of it, so a fetched
+// repository or archive cannot point the app at files elsewhere on the host.
+func checkSymlinks(dir string) error {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if os.IsNotExist(err) {
+			// dangling, check where it would lead
+			if target, err = os.Readlink(path); err == nil && !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !isSubpath(dir, target) {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("symlink '%s' leads outside of the app", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+// isSubpath reports whether path is dir or inside it.
+func isSubpath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+func extractZip(archivePath, dest string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		path := filepath.Join(dest, f.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path in zip: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := extractZipFile(f, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, path string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode())
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = io.Copy(fh, rc)
+	return err
+}
+
+func downloadToFile(uri, path string) error {
+	r, err := downloadAsStream(uri)
+	if err != nil {
+		return err
+	}
+	if rc, ok := r.(io.Closer); ok {
+		defer rc.Close()
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = io.Copy(fh, r)
+	return err
+}
+
+func isGitSource(path string) bool {
+	location := path
+	if i := strings.LastIndex(location, "#"); i >= 0 {
+		location = location[:i]
+	}
+	return strings.HasPrefix(location, "git@") ||
+		strings.HasPrefix(location, "git://") ||
+		strings.HasPrefix(location, "git+") ||
+		strings.HasSuffix(location, ".git")
+}
+
+func isRemote(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "git", "ssh", "file":
+		return true
+	}
+	return false
+}
+
+func archiveExt(path string) string {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		path = u.Path
+	}
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(path, ext) {
+			return ext
+		}
+	}
+	return ""
+}
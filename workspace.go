@@ -0,0 +1,187 @@
+package pack
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/pack/config"
+	"github.com/buildpack/pack/fs"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	dockercli "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+const appVolumePrefix = "pack-app-"
+
+// AppVolumeLabel marks the app volumes pack run keeps between builds. Like
+// cache volumes they are not left behind by accident, so pack prune only
+// removes them when asked to.
+const AppVolumeLabel = "io.buildpacks.pack.app"
+
+// AppVolumeName names the volume an app is kept in between builds, when only
+// what changed is copied to the workspace.
+func AppVolumeName(appDir string) string {
+	return fmt.Sprintf("%s%x", appVolumePrefix, md5.Sum([]byte(appDir)))
+}
+
+// appManifest records what was copied to an app volume. It only holds for the
+// volume created at VolumeCreatedAt and files owned by UID and GID.
+type appManifest struct {
+	VolumeCreatedAt string      `json:"volume-created-at"`
+	UID             int         `json:"uid"`
+	GID             int         `json:"gid"`
+	Files           fs.Manifest `json:"files"`
+}
+
+func (b *BuildConfig) appManifestPath() string {
+	return appManifestPath(b.Config, b.AppVolume)
+}
+
+func appManifestPath(cfg *config.Config, appVolume string) string {
+	return filepath.Join(cfg.Dir(), "workspaces", appVolume+".json")
+}
+
+// prepareAppVolume creates the app volume and returns what it holds. A volume
+// that does not match its manifest, or has none, holds who knows what, so it
+// is replaced with an empty one. It has to run before any container uses the
+// volume.
+func (b *BuildConfig) prepareAppVolume(ctx context.Context, uid, gid int) (*appManifest, error) {
+	labels := map[string]string{AppVolumeLabel: "true"}
+	vol, err := b.Cli.VolumeCreate(ctx, volume.VolumeCreateBody{Name: b.AppVolume, Labels: labels})
+	if err != nil {
+		return nil, errors.Wrap(err, "create app volume")
+	}
+	var manifest appManifest
+	if buf, err := ioutil.ReadFile(b.appManifestPath()); err == nil && json.Unmarshal(buf, &manifest) == nil &&
+		manifest.Files != nil && manifest.VolumeCreatedAt == vol.CreatedAt && manifest.UID == uid && manifest.GID == gid {
+		return &manifest, nil
+	}
+
+	b.Log.Verbose("Copying the whole app to a new app volume '%s'", b.AppVolume)
+	if err := b.Cli.VolumeRemove(ctx, b.AppVolume, true); err != nil && !dockercli.IsErrNotFound(err) {
+		return nil, errors.Wrap(err, "remove app volume")
+	}
+	if vol, err = b.Cli.VolumeCreate(ctx, volume.VolumeCreateBody{Name: b.AppVolume, Labels: labels}); err != nil {
+		return nil, errors.Wrap(err, "create app volume")
+	}
+	return &appManifest{VolumeCreatedAt: vol.CreatedAt, UID: uid, GID: gid}, nil
+}
+
+// syncAppScript runs in a container with the app volume at /pack-app. It
+// deletes what was removed from the app, listed in /pack-removed, and copies
+// the volume to a workspace app dir of its own.
+const syncAppScript = `cd /pack-app && if [ -s /pack-removed ]; then xargs -0 rm -rf -- < /pack-removed; fi && rm -rf /workspace/app && cp -a /pack-app /workspace/app`
+
+// syncAppVolume brings the app volume up to date with the app dir, copying
+// only the files added or changed since the last build, and then copies it to
+// /workspace/app. The app volume is only ever mounted for this, so what
+// buildpacks do to the app dir never makes it back into the volume.
+func (b *BuildConfig) syncAppVolume(ctx context.Context, uid, gid int) error {
+	manifest, err := b.prepareAppVolume(ctx, uid, gid)
+	if err != nil {
+		return err
+	}
+	previous := manifest.Files
+	if previous == nil {
+		previous = fs.Manifest{}
+	}
+	// should the sync fail half way, the volume is started over next time
+	if err := os.Remove(b.appManifestPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	current, changed, removed, err := b.appChanges(previous)
+	if err != nil {
+		return err
+	}
+
+	ctr, err := b.Cli.ContainerCreate(ctx, &container.Config{
+		Image:  b.Builder,
+		Cmd:    []string{"sh", "-c", syncAppScript},
+		User:   "root",
+		Labels: managedLabels(),
+	}, &container.HostConfig{
+		Binds: []string{
+			b.WorkspaceVolume + ":/workspace",
+			b.AppVolume + ":/pack-app",
+		},
+	}, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "app volume container create")
+	}
+	defer b.removeContainer(ctr.ID)
+
+	if err := b.copyAppFiles(ctx, ctr.ID, "/pack-app", changed, uid, gid); err != nil {
+		return errors.Wrap(err, "copy app to app volume")
+	}
+	// passed in a file, there may be too many for a command line
+	tr, err := b.FS.CreateSingleFileTar("/pack-removed", strings.Join(removed, "\x00"))
+	if err != nil {
+		return err
+	}
+	if err := b.Cli.CopyToContainer(ctx, ctr.ID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
+		return errors.Wrap(err, "copy list of deleted app files")
+	}
+	if err := b.Cli.RunContainer(ctx, ctr.ID, b.Stdout, b.Stderr); err != nil {
+		return errors.Wrap(err, "copy app volume to workspace volume")
+	}
+	b.Log.Verbose("Copied %d changed and removed %d deleted app files", len(changed), len(removed))
+
+	manifest.Files = current
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.appManifestPath()), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(b.appManifestPath(), buf, 0666)
+}
+
+// copyApp copies the whole app to /workspace/app through the container ctrID,
+// owned by uid and gid.
+func (b *BuildConfig) copyApp(ctx context.Context, ctrID string, uid, gid int) error {
+	_, changed, _, err := b.appChanges(nil)
+	if err != nil {
+		return err
+	}
+	if err := b.copyAppFiles(ctx, ctrID, "/workspace/app", changed, uid, gid); err != nil {
+		return errors.Wrap(err, "copy app to workspace volume")
+	}
+	return nil
+}
+
+// appChanges compares the app dir, less what is excluded, with previous, see
+// fs.Changes.
+func (b *BuildConfig) appChanges(previous fs.Manifest) (fs.Manifest, []string, []string, error) {
+	ignore := fs.NewIgnore(b.Exclude)
+	ignore.IncludeOnly(b.Include)
+	current, changed, removed, err := fs.Changes(b.AppDir, ignore, previous)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "read app dir")
+	}
+	if ignore.SkippedFiles > 0 {
+		b.Log.Info("Excluded %d files (%d bytes) from app upload", ignore.SkippedFiles, ignore.SkippedBytes)
+	}
+	return current, changed, removed, nil
+}
+
+// copyAppFiles copies paths of the app dir to tarDir through the container
+// ctrID, owned by uid and gid.
+func (b *BuildConfig) copyAppFiles(ctx context.Context, ctrID, tarDir string, paths []string, uid, gid int) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	tr, errChan := b.FS.CreateFilesTarReader(b.AppDir, tarDir, paths, uid, gid)
+	if err := b.Cli.CopyToContainer(ctx, ctrID, "/", tr, dockertypes.CopyToContainerOptions{}); err != nil {
+		return err
+	}
+	return <-errChan
+}